@@ -0,0 +1,50 @@
+package redisgo
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// aliasConn 按 Options.CommandAliases 把命令名替换为重命名后的名称再发送，
+// 包装在建立连接时，所以连接池、管道、Lua脚本和订阅等所有发送命令的地方都会生效。
+type aliasConn struct {
+	redis.Conn
+	aliases map[string]string
+}
+
+// newAliases 将命令别名的键统一转为大写
+func newAliases(aliases map[string]string) map[string]string {
+	result := make(map[string]string, len(aliases))
+	for name, alias := range aliases {
+		result[strings.ToUpper(name)] = alias
+	}
+	return result
+}
+
+// alias 返回命令重命名后的名称，没有重命名时原样返回
+func (c aliasConn) alias(commandName string) string {
+	if alias, ok := c.aliases[strings.ToUpper(commandName)]; ok {
+		return alias
+	}
+	return commandName
+}
+
+func (c aliasConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.Conn.Do(c.alias(commandName), args...)
+}
+
+func (c aliasConn) Send(commandName string, args ...interface{}) error {
+	return c.Conn.Send(c.alias(commandName), args...)
+}
+
+// DoWithTimeout 实现 redis.ConnWithTimeout ，以便 redis.DoWithTimeout 可以使用包装后的连接
+func (c aliasConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(c.Conn, timeout, c.alias(commandName), args...)
+}
+
+// ReceiveWithTimeout 实现 redis.ConnWithTimeout
+func (c aliasConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
@@ -0,0 +1,177 @@
+package redisgo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// exportMagic Export 输出的数据开头的标记，包含格式的版本号
+const exportMagic = "RGOEXP01"
+
+// exportRecord Export 输出的一个键
+type exportRecord struct {
+	key  string // 去掉前缀后的键名
+	ttl  int64  // 剩余有效期（毫秒），0表示没有设置有效期
+	data []byte // DUMP 返回的序列化数据
+}
+
+// Export 使用 SCAN 遍历前缀下的所有键，通过 DUMP 和 PTTL 导出键的数据及剩余有效期，写入 w 。不会导出其他前缀的键，可以用于按命名空间备份和迁移。
+// 输出的格式为：8字节的标记 "RGOEXP01" ，之后每个键依次为：
+//
+//	4字节的键名长度（大端序，下同）、去掉前缀后的键名、8字节的剩余有效期（毫秒，0表示没有有效期）、4字节的数据长度、DUMP 返回的数据
+//
+// DUMP 的数据格式与redis版本相关，只能导入到相同或更高版本的redis中。遍历期间修改的键可能导出修改前或修改后的数据，遍历期间过期的键会被忽略。
+func (c *Cacher) Export(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(exportMagic); err != nil {
+		return err
+	}
+	var cursor uint64
+	for {
+		keys, next, err := c.ScanPage(cursor, "", 0)
+		if err != nil {
+			return err
+		}
+		records, err := c.dumpKeys(keys)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := writeExportRecord(bw, record); err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	return bw.Flush()
+}
+
+// Import 读取 Export 输出的数据，使用 RESTORE 恢复每个键及其剩余有效期。键名会加上当前Cacher的前缀，所以可以导入到其他前缀下。
+// 已经存在的键会被替换（RESTORE ... REPLACE）。出错时已经导入的键不会回滚。
+func (c *Cacher) Import(r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return fmt.Errorf("redisgo: Import: read header: %w", err)
+	}
+	if string(magic) != exportMagic {
+		return fmt.Errorf("redisgo: Import: unknown format %q", magic)
+	}
+	for {
+		record, err := readExportRecord(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("redisgo: Import: %w", err)
+		}
+		if record.key == "" {
+			return ErrEmptyKey
+		}
+		if _, err := c.Do("RESTORE", c.getKey(record.key), record.ttl, record.data, "REPLACE"); err != nil {
+			return err
+		}
+	}
+}
+
+// dumpKeys 使用管道查询键的 DUMP 和 PTTL ，忽略查询期间已经不存在的键
+func (c *Cacher) dumpKeys(keys []string) ([]exportRecord, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	conn := c.getConn()
+	defer conn.Close()
+	for _, key := range keys {
+		if err := conn.Send("DUMP", c.getKey(key)); err != nil {
+			return nil, wrapError("DUMP", err)
+		}
+		if err := conn.Send("PTTL", c.getKey(key)); err != nil {
+			return nil, wrapError("PTTL", err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, wrapError("DUMP", err)
+	}
+	replies, errs := receiveAll(conn, 2*len(keys))
+	records := make([]exportRecord, 0, len(keys))
+	var err error
+	for i, key := range keys {
+		data, e := redis.Bytes(replies[2*i], errs[2*i])
+		if e != nil && e != ErrNil && err == nil {
+			err = wrapError("DUMP", e)
+		}
+		ttl, e2 := redis.Int64(replies[2*i+1], errs[2*i+1])
+		if e2 != nil && err == nil {
+			err = wrapError("PTTL", e2)
+		}
+		if e != nil || e2 != nil || ttl == -2 {
+			continue
+		}
+		if ttl < 0 {
+			ttl = 0
+		}
+		records = append(records, exportRecord{key: key, ttl: ttl, data: data})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// writeExportRecord 按 Export 的格式写入一个键
+func writeExportRecord(w io.Writer, record exportRecord) error {
+	buf := make([]byte, 16+len(record.key)+len(record.data))
+	n := 0
+	binary.BigEndian.PutUint32(buf[n:], uint32(len(record.key)))
+	n += 4
+	n += copy(buf[n:], record.key)
+	binary.BigEndian.PutUint64(buf[n:], uint64(record.ttl))
+	n += 8
+	binary.BigEndian.PutUint32(buf[n:], uint32(len(record.data)))
+	n += 4
+	copy(buf[n:], record.data)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readExportRecord 按 Export 的格式读取一个键，已经读到末尾时返回 io.EOF ，数据不完整时返回 io.ErrUnexpectedEOF
+func readExportRecord(r io.Reader) (exportRecord, error) {
+	var record exportRecord
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:4]); err != nil {
+		return record, err
+	}
+	key := make([]byte, binary.BigEndian.Uint32(header[:4]))
+	if _, err := io.ReadFull(r, key); err != nil {
+		return record, noEOF(err)
+	}
+	if _, err := io.ReadFull(r, header[:8]); err != nil {
+		return record, noEOF(err)
+	}
+	record.key = string(key)
+	record.ttl = int64(binary.BigEndian.Uint64(header[:8]))
+	if _, err := io.ReadFull(r, header[:4]); err != nil {
+		return record, noEOF(err)
+	}
+	record.data = make([]byte, binary.BigEndian.Uint32(header[:4]))
+	if _, err := io.ReadFull(r, record.data); err != nil {
+		return record, noEOF(err)
+	}
+	return record, nil
+}
+
+// noEOF 将读取一个键的中途遇到的 io.EOF 转换为 io.ErrUnexpectedEOF
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package redisgo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// registerDumpRestore 为miniredis注册只支持字符串的 DUMP 和 RESTORE 命令（miniredis没有实现这两个命令）
+func registerDumpRestore(t *testing.T, s *miniredis.Miniredis) {
+	err := s.Server().Register("DUMP", func(p *server.Peer, cmd string, args []string) {
+		value, err := s.Get(args[0])
+		if err != nil {
+			p.WriteNull()
+			return
+		}
+		p.WriteBulk("dump:" + value)
+	})
+	NoError(t, err)
+	err = s.Server().Register("RESTORE", func(p *server.Peer, cmd string, args []string) {
+		s.Set(args[0], strings.TrimPrefix(args[2], "dump:"))
+		if ttl, _ := strconv.Atoi(args[1]); ttl > 0 {
+			s.SetTTL(args[0], time.Duration(ttl)*time.Millisecond)
+		}
+		p.WriteInline("OK")
+	})
+	NoError(t, err)
+}
+
+func TestExportImport(t *testing.T) {
+	s, err := miniredis.Run()
+	NoError(t, err)
+	defer s.Close()
+	registerDumpRestore(t, s)
+	src, err := New(Options{Addr: s.Addr(), Prefix: "tenant1", DisableSignalClose: true})
+	NoError(t, err)
+	dst, err := New(Options{Addr: s.Addr(), Prefix: "tenant2", DisableSignalClose: true})
+	NoError(t, err)
+	NoError(t, src.Set("name", "corel", 0))
+	NoError(t, src.Set("session", "abc", 60))
+	NoError(t, dst.Set("other", "untouched", 0))
+
+	var buf bytes.Buffer
+	NoError(t, src.Export(&buf))
+	data := buf.Bytes()
+	NoError(t, dst.Import(bytes.NewReader(data)))
+
+	name, err := dst.GetString("name")
+	NoError(t, err)
+	Equal(t, "corel", name)
+	ttl, err := dst.TTL("session")
+	NoError(t, err)
+	Equal(t, int64(60), ttl)
+	other, err := dst.GetString("other")
+	NoError(t, err)
+	Equal(t, "untouched", other)
+
+	err = dst.Import(bytes.NewReader(data[:len(data)-1]))
+	Equal(t, true, errors.Is(err, io.ErrUnexpectedEOF))
+	Error(t, dst.Import(strings.NewReader("not an export")))
+}
@@ -0,0 +1,86 @@
+package redisgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// circuitBreaker 熔断器：连续 threshold 次连接失败后打开，打开期间命令直接返回 ErrCircuitOpen ；
+// 经过 cooldown 后进入半开状态，只放行一条命令探测redis是否恢复，成功则关闭，失败则重新打开。
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int       // 连续失败的次数
+	openedAt  time.Time // 打开的时间，为零值时表示关闭
+	probing   bool      // 半开状态下是否已经有命令在探测
+}
+
+// newCircuitBreaker threshold 小于等于0时不使用熔断器，返回nil
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if cooldown <= 0 {
+		cooldown = 10 * time.Second
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow 检查是否可以执行命令
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if time.Since(b.openedAt) < b.cooldown || b.probing {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// record 记录命令的执行结果，只有连接错误才算作失败，redis返回的错误和 ErrNil 说明服务是正常的。
+// 连接池耗尽、ctx 取消或到期（包括 ctx 到期导致的读写超时）等不能说明redis是否可用的错误不计入结果，半开状态下允许其他命令重新探测。
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if isCallerError(err) || (err != nil && ctx.Err() != nil) {
+		b.probing = false
+		return
+	}
+	if !isConnError(err) {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		b.probing = false
+		return
+	}
+	b.failures++
+	if b.probing || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+}
+
+// isConnError 判断是否为连接失败、连接断开、网络超时等说明redis不可用的网络错误
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// isCallerError 判断是否为连接池耗尽、ctx 取消或到期、连接池已关闭等调用方一侧的错误
+func isCallerError(err error) bool {
+	return errors.Is(err, redis.ErrPoolExhausted) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrClosed)
+}
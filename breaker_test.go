@@ -0,0 +1,66 @@
+package redisgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	s := miniredis.RunT(t)
+	c, err := New(Options{Addr: s.Addr(), CircuitBreakerThreshold: 2, CircuitBreakerCooldown: 200 * time.Millisecond, DisableSignalClose: true})
+	NoError(t, err)
+	defer c.Close()
+	// redis返回的错误不会触发熔断
+	for i := 0; i < 3; i++ {
+		_, err = c.Do("NOSUCHCOMMAND")
+		Error(t, err)
+	}
+	_, err = c.Do("PING")
+	NoError(t, err)
+
+	s.Close()
+	for i := 0; i < 2; i++ {
+		_, err = c.Do("PING")
+		Error(t, err)
+		Equal(t, false, errors.Is(err, ErrCircuitOpen))
+	}
+	_, err = c.Do("PING")
+	Equal(t, true, errors.Is(err, ErrCircuitOpen))
+
+	NoError(t, s.Restart())
+	time.Sleep(250 * time.Millisecond)
+	_, err = c.Do("PING")
+	NoError(t, err)
+	_, err = c.Do("PING")
+	NoError(t, err)
+}
+
+func TestCircuitBreakerCallerErrors(t *testing.T) {
+	s := miniredis.RunT(t)
+	c, err := New(Options{Addr: s.Addr(), MaxActive: 1, CircuitBreakerThreshold: 1, CircuitBreakerCooldown: time.Minute, DisableSignalClose: true})
+	NoError(t, err)
+	defer c.Close()
+
+	// 连接池耗尽时redis仍然可用，不会触发熔断
+	conn := c.getConn()
+	for i := 0; i < 3; i++ {
+		_, err = c.Do("PING")
+		Equal(t, true, errors.Is(err, redis.ErrPoolExhausted))
+	}
+	conn.Close()
+	_, err = c.Do("PING")
+	NoError(t, err)
+
+	// ctx 到期导致的超时也不会触发熔断
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err = c.DoContext(ctx, "PING")
+	Error(t, err)
+	_, err = c.Do("PING")
+	NoError(t, err)
+}
@@ -0,0 +1,144 @@
+package redisgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// gzipMagic gzip数据的文件头，同时作为压缩数据的标记。json等文本序列化的数据不会以这两个字节开头。
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// gzipWriters 复用gzip.Writer，每次新建都要分配几百KB的压缩状态
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipReaders 复用gzip.Reader，池中为空时返回nil，由调用方使用第一份数据创建
+var gzipReaders sync.Pool
+
+// bufferPool 复用解压时使用的缓冲区
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// jsonDecoder 复用的json.Decoder，通过 src 切换读取的数据，复用 Decoder 内部的缓冲区和解码状态
+type jsonDecoder struct {
+	src   readerSwitch
+	dec   *json.Decoder
+	bytes bytes.Reader
+}
+
+// readerSwitch 读取 r 的数据，r 可以替换
+type readerSwitch struct {
+	r io.Reader
+}
+
+func (s *readerSwitch) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+// jsonDecoders 复用jsonDecoder，池中为空时返回nil
+var jsonDecoders sync.Pool
+
+// decodeJSON 使用复用的json.Decoder从 r 中读取一个JSON值到 v ，效果与 json.Unmarshal 相同
+func decodeJSON(r io.Reader, v interface{}) error {
+	return getJSONDecoder().decode(r, v)
+}
+
+// decodeJSONBytes 使用复用的json.Decoder将 data 反序列化到 v ，效果与 json.Unmarshal 相同
+func decodeJSONBytes(data []byte, v interface{}) error {
+	d := getJSONDecoder()
+	d.bytes.Reset(data)
+	return d.decode(&d.bytes, v)
+}
+
+// getJSONDecoder 从 jsonDecoders 获取jsonDecoder，池中为空时新建
+func getJSONDecoder() *jsonDecoder {
+	d, _ := jsonDecoders.Get().(*jsonDecoder)
+	if d == nil {
+		d = &jsonDecoder{}
+		d.dec = json.NewDecoder(&d.src)
+	}
+	return d
+}
+
+// decode 从 r 中读取一个JSON值到 v 。与 json.Unmarshal 一致，值的后面只能有空白字符。
+// 出错时 Decoder 的状态无法复用，不放回池中。
+func (d *jsonDecoder) decode(r io.Reader, v interface{}) error {
+	d.src.r = r
+	err := d.dec.Decode(v)
+	if err == nil {
+		// 读到数据末尾时返回 io.EOF ，同时跳过了值后面的空白字符，Decoder 中不会留下这份数据的内容
+		var tok json.Token
+		if tok, err = d.dec.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = fmt.Errorf("redisgo: unexpected %v after top-level value", tok)
+		}
+	}
+	d.src.r = nil
+	d.bytes.Reset(nil)
+	if err == nil {
+		jsonDecoders.Put(d)
+	}
+	return err
+}
+
+// gzipCompress 使用gzip压缩数据
+func gzipCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gzipReader 从 gzipReaders 获取读取 data 的gzip.Reader，使用完后需要调用 release 放回
+func gzipReader(data []byte) (r *gzip.Reader, release func(), err error) {
+	if v := gzipReaders.Get(); v != nil {
+		r = v.(*gzip.Reader)
+		err = r.Reset(bytes.NewReader(data))
+	} else {
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	release = func() {
+		r.Close()
+		gzipReaders.Put(r)
+	}
+	return r, release, nil
+}
+
+// gzipDecompress 解压使用gzip压缩的数据。
+// 解压后的数据保存在从 bufferPool 获取的缓冲区中，使用完后需要调用 release 放回。
+func gzipDecompress(data []byte) (result []byte, release func(), err error) {
+	r, releaseReader, err := gzipReader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer releaseReader()
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	release = func() { bufferPool.Put(buf) }
+	if _, err := io.Copy(buf, r); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return buf.Bytes(), release, nil
+}
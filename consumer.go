@@ -0,0 +1,152 @@
+package redisgo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ConsumeOptions ConsumeQueues 的参数
+type ConsumeOptions struct {
+	Context         context.Context                            // 取消时停止消费并返回，默认为 context.Background()，即一直消费
+	Timeout         time.Duration                              // 每次阻塞等待的时长，到期后检查 Context 是否已取消，默认为1秒
+	FromRight       bool                                       // 是否从列表右侧（表尾）弹出，默认从左侧（表头）弹出
+	ProcessingQueue string                                     // 处理中列表，不为空时为至少一次的语义，见 ConsumeQueues 的说明
+	OnError         func(queue string, item []byte, err error) // handler 返回错误或执行命令出错时的回调，执行命令出错时 item 为nil
+}
+
+// ConsumeQueues 按优先级从多个列表队列中消费元素，queues 中越靠前的队列优先级越高。
+// 每个元素都会调用一次 handler ，queue 为元素所在的队列名（不含前缀）。执行命令出错时等待1秒后继续消费。
+//
+// 没有设置 ProcessingQueue 时，使用 BLMPOP 阻塞弹出元素（需要redis 7.0及以上版本），元素弹出后即从队列中删除，
+// handler 返回错误或程序退出时元素会丢失，即至多一次的语义。
+//
+// 设置了 ProcessingQueue 时，使用 LMOVE （需要redis 6.2及以上版本）按优先级把元素原子地移动到处理中列表，handler 成功后才从处理中列表删除，
+// handler 返回错误或程序退出时元素保留在处理中列表中，可以由其他程序重新放回队列，即至少一次的语义，handler 需要保证幂等。
+// 由于redis没有可以同时阻塞等待多个列表的移动命令，这种方式在所有队列都为空时每隔 Timeout 轮询一次。
+func (c *Cacher) ConsumeQueues(queues []string, handler func(queue string, item []byte) error, opts ConsumeOptions) error {
+	if len(queues) == 0 {
+		return fmt.Errorf("redisgo: ConsumeQueues: no queues")
+	}
+	for _, queue := range queues {
+		if queue == "" {
+			return ErrEmptyKey
+		}
+	}
+	if opts.ProcessingQueue == "" {
+		if err := c.checkSupport("BLMPOP"); err != nil {
+			return err
+		}
+	} else if err := c.checkSupport("LMOVE"); err != nil {
+		return err
+	}
+	if opts.Context == nil {
+		opts.Context = context.Background()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	for opts.Context.Err() == nil {
+		var queue string
+		var item []byte
+		var err error
+		if opts.ProcessingQueue == "" {
+			queue, item, err = c.blmpop(queues, opts)
+		} else {
+			queue, item, err = c.lmove(queues, opts)
+		}
+		if err != nil {
+			if opts.OnError != nil {
+				opts.OnError(queue, nil, err)
+			}
+			sleepContext(opts.Context, time.Second)
+			continue
+		}
+		if item == nil {
+			if opts.ProcessingQueue != "" {
+				sleepContext(opts.Context, opts.Timeout)
+			}
+			continue
+		}
+		if err := handler(queue, item); err != nil {
+			if opts.OnError != nil {
+				opts.OnError(queue, item, err)
+			}
+			continue
+		}
+		if opts.ProcessingQueue != "" {
+			if _, err := c.Do("LREM", c.getKey(opts.ProcessingQueue), 1, item); err != nil && opts.OnError != nil {
+				opts.OnError(queue, item, err)
+			}
+		}
+	}
+	return nil
+}
+
+// blmpop 使用 BLMPOP 按优先级阻塞弹出一个元素，超时返回nil
+func (c *Cacher) blmpop(queues []string, opts ConsumeOptions) (string, []byte, error) {
+	args := redis.Args{}.Add(opts.Timeout.Seconds(), len(queues))
+	names := make(map[string]string, len(queues))
+	for _, queue := range queues {
+		names[c.getKey(queue)] = queue
+		args = args.Add(c.getKey(queue))
+	}
+	args = args.Add(direction(opts.FromRight), "COUNT", 1)
+	values, err := redis.Values(c.DoContext(opts.Context, "BLMPOP", args...))
+	if err == ErrNil {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if len(values) != 2 {
+		return "", nil, fmt.Errorf("%w: unexpected number of values, got %d", ErrUnexpectedReply, len(values))
+	}
+	key, err := redis.String(values[0], nil)
+	if err != nil {
+		return "", nil, err
+	}
+	items, err := redis.ByteSlices(values[1], nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(items) == 0 {
+		return "", nil, nil
+	}
+	return names[key], items[0], nil
+}
+
+// lmove 按优先级把第一个非空队列中的一个元素移动到处理中列表，所有队列都为空时返回nil
+func (c *Cacher) lmove(queues []string, opts ConsumeOptions) (string, []byte, error) {
+	for _, queue := range queues {
+		item, err := redis.Bytes(c.DoContext(opts.Context, "LMOVE", c.getKey(queue), c.getKey(opts.ProcessingQueue), direction(opts.FromRight), "RIGHT"))
+		if err == ErrNil {
+			continue
+		}
+		if err != nil {
+			return queue, nil, err
+		}
+		return queue, item, nil
+	}
+	return "", nil, nil
+}
+
+// sleepContext 等待d时长，ctx取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// direction 返回列表命令的方向参数
+func direction(right bool) string {
+	if right {
+		return "RIGHT"
+	}
+	return "LEFT"
+}
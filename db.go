@@ -0,0 +1,79 @@
+package redisgo
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultDatabases redis服务默认的数据库数量
+const defaultDatabases = 16
+
+// ForEachDB 依次在每个数据库上执行 fn ，可以用于统计各个数据库的键数量等跨数据库的管理操作。
+// 每个数据库都使用单独建立的连接，执行 SELECT 后传给 fn 的Cacher只使用这些连接，fn 返回后关闭这些连接，
+// 不会放回共用的连接池，以免其他命令在错误的数据库上执行。fn 返回错误时停止遍历并返回该错误。
+// 数据库数量在遍历前通过 CONFIG GET databases 获取一次，CONFIG 命令不可用（服务端返回错误）时默认为16个，连接出错时返回错误。
+// 传给 fn 的Cacher不使用客户端缓存（Tracking），在 fn 中通过它的 Route 创建的连接池也会在 fn 返回后关闭。
+func (c *Cacher) ForEachDB(fn func(db int, c *Cacher) error) error {
+	databases, err := c.databases()
+	if err != nil {
+		return err
+	}
+	for db := 0; db < databases; db++ {
+		if err := c.withDB(db, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withDB 使用单独的连接池在指定的数据库上执行 fn ，执行完后关闭连接池，以及 fn 中通过 Route 创建的其他数据库的连接池
+func (c *Cacher) withDB(db int, fn func(db int, c *Cacher) error) error {
+	pool := &redis.Pool{
+		MaxIdle: 1,
+		Dial: func() (redis.Conn, error) {
+			conn, err := c.state().dial()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := conn.Do("SELECT", db); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		},
+	}
+	parent := c.state()
+	opts := parent.opts
+	opts.Db = db
+	setup := func(conn redis.Conn) error {
+		if err := parent.setup(conn); err != nil {
+			return err
+		}
+		_, err := conn.Do("SELECT", db)
+		return err
+	}
+	state := &connState{pool: pool, dial: parent.dial, setup: setup, serverVersion: parent.serverVersion, opts: opts}
+	defer state.close()
+	cc := *c
+	cc.conns = &connHolder{state: state}
+	return fn(db, &cc)
+}
+
+// databases 返回redis服务的数据库数量。CONFIG 命令被禁用或没有权限等服务端返回错误时，使用默认的数量
+func (c *Cacher) databases() (int, error) {
+	config, err := c.ConfigGet("databases")
+	var e redis.Error
+	if errors.As(err, &e) {
+		return defaultDatabases, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(config["databases"])
+	if err != nil || n <= 0 {
+		return defaultDatabases, nil
+	}
+	return n, nil
+}
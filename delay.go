@@ -0,0 +1,50 @@
+package redisgo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// delayPopScript 原子地取出并删除到期的任务，避免多个消费者取到同一个任务。
+// unpack 的参数数量受Lua栈大小的限制，所以每次 ZREM 最多删除 ARGV[3] 个任务。
+var delayPopScript = redis.NewScript(1, `
+local items = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+local batch = tonumber(ARGV[3])
+for i = 1, #items, batch do
+	redis.call("ZREM", KEYS[1], unpack(items, i, math.min(i + batch - 1, #items)))
+end
+return items`)
+
+// delayRemoveBatch delayPopScript 每次 ZREM 删除的任务数量
+const delayRemoveBatch = 1000
+
+// DelayPush 将任务加入基于有序集的延时队列，runAt 为任务的执行时间，作为分数保存（unix时间戳，精度为毫秒）。
+// payload 与 Set 相同地序列化。由于有序集的成员是唯一的，内容完全相同的任务只会保存一个，执行时间以最后一次加入的为准，
+// 需要区分时可以在 payload 中加上任务ID。
+func (c *Cacher) DelayPush(queue string, payload interface{}, runAt time.Time) error {
+	if queue == "" {
+		return ErrEmptyKey
+	}
+	value, err := c.encode(payload)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("ZADD", c.getKey(queue), delayScore(runAt), value)
+	return err
+}
+
+// DelayPop 从延时队列中取出最多 max 个执行时间不晚于 now 的任务，按执行时间从早到晚返回序列化后的数据，可以使用 Scan 反序列化。
+// 使用Lua脚本原子地查询并删除，多个消费者同时调用时不会取到同一个任务。没有到期的任务时返回空切片。
+func (c *Cacher) DelayPop(queue string, now time.Time, max int) ([][]byte, error) {
+	if max <= 0 {
+		return nil, fmt.Errorf("redisgo: DelayPop: max must be positive, got %d", max)
+	}
+	return redis.ByteSlices(c.Eval(delayPopScript, []string{queue}, delayScore(now), max, delayRemoveBatch))
+}
+
+// delayScore 将时间转换为延时队列的分数
+func delayScore(t time.Time) float64 {
+	return float64(t.UnixNano()/int64(time.Millisecond)) / 1000
+}
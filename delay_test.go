@@ -0,0 +1,55 @@
+package redisgo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDelayQueue(t *testing.T) {
+	c := getCacher()
+	c.Del("delayed_jobs")
+	now := time.Now()
+	err := c.DelayPush("delayed_jobs", "send-email", now.Add(-time.Minute))
+	NoError(t, err)
+	err = c.DelayPush("delayed_jobs", User{Name: "corel", Age: 23}, now.Add(-time.Second))
+	NoError(t, err)
+	err = c.DelayPush("delayed_jobs", "cleanup", now.Add(time.Hour))
+	NoError(t, err)
+
+	jobs, err := c.DelayPop("delayed_jobs", now, 10)
+	NoError(t, err)
+	Equal(t, 2, len(jobs))
+	Equal(t, "send-email", string(jobs[0]))
+	var user User
+	err = c.Scan(jobs[1], nil, &user)
+	NoError(t, err)
+	Equal(t, User{Name: "corel", Age: 23}, user)
+
+	jobs, err = c.DelayPop("delayed_jobs", now, 10)
+	NoError(t, err)
+	Equal(t, 0, len(jobs))
+	jobs, err = c.DelayPop("delayed_jobs", now.Add(2*time.Hour), 10)
+	NoError(t, err)
+	Equal(t, [][]byte{[]byte("cleanup")}, jobs)
+}
+
+func TestDelayPopMany(t *testing.T) {
+	c := getCacher()
+	c.Del("delayed_many")
+	// 一次 unpack 所有任务会超过Lua栈的限制，超过 delayRemoveBatch 的任务分批 ZREM
+	now := time.Now()
+	commands := make([][]interface{}, 0, 20*delayRemoveBatch+1)
+	for i := 0; i < 20*delayRemoveBatch+1; i++ {
+		commands = append(commands, []interface{}{"ZADD", c.Key("delayed_many"), delayScore(now.Add(-time.Minute)), fmt.Sprintf("job-%d", i)})
+	}
+	_, err := c.DoMany(commands)
+	NoError(t, err)
+
+	jobs, err := c.DelayPop("delayed_many", now, len(commands))
+	NoError(t, err)
+	Equal(t, len(commands), len(jobs))
+	n, err := Int64(c.Do("ZCARD", c.Key("delayed_many")))
+	NoError(t, err)
+	Equal(t, int64(0), n)
+}
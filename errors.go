@@ -0,0 +1,50 @@
+package redisgo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var (
+	// ErrNil 键不存在或值为nil。与 redis.ErrNil 是同一个值，两者都可以用于 errors.Is 判断。
+	ErrNil = redis.ErrNil
+	// ErrEmptyKey 键名为空。空键名加上前缀后会把前缀本身当作键名，所以所有以键名为参数的方法都会拒绝空键名。
+	ErrEmptyKey = errors.New("redisgo: empty key")
+	// ErrClosed 连接池已经关闭
+	ErrClosed = errors.New("redisgo: pool closed")
+	// ErrUnsupportedOptions StartAndGC传入了不支持的配置参数类型
+	ErrUnsupportedOptions = errors.New("redisgo: unsupported options")
+	// ErrUnsupported redis服务的版本不支持要执行的命令
+	ErrUnsupported = errors.New("redisgo: unsupported command")
+	// ErrUnexpectedReply redis返回的结果格式与预期不符
+	ErrUnexpectedReply = errors.New("redisgo: unexpected reply")
+	// ErrCircuitOpen 熔断器打开，redis被认为不可用，命令没有执行
+	ErrCircuitOpen = errors.New("redisgo: circuit breaker is open")
+	// ErrCrossShard ShardedCacher 的多键操作中，键分布在不同的节点上
+	ErrCrossShard = errors.New("redisgo: keys belong to different shards")
+)
+
+// errPoolClosed redigo在连接池关闭后获取连接时返回的错误信息
+const errPoolClosed = "redigo: get on closed pool"
+
+// wrapError 为命令执行的错误加上命令名称，原始错误可以通过 errors.Is 和 errors.As 获取。
+// redis返回的错误信息可以通过 errors.As 获取为 redis.Error 类型。
+func wrapError(commandName string, err error) error {
+	if err == nil || err == ErrNil {
+		return err
+	}
+	if err.Error() == errPoolClosed {
+		return fmt.Errorf("redisgo: %s: %w", commandName, ErrClosed)
+	}
+	return fmt.Errorf("redisgo: %s: %w", commandName, err)
+}
+
+// IsWrongType 判断错误是否为redis返回的 WRONGTYPE 错误，即键中保存的数据结构与命令不符（比如对字符串执行 LPUSH）。
+// 这种错误是调用方的问题，重试也不会成功，不应该当作网络异常等临时错误处理。
+func IsWrongType(err error) bool {
+	var e redis.Error
+	return errors.As(err, &e) && strings.HasPrefix(string(e), "WRONGTYPE")
+}
@@ -1,3 +1,10 @@
 module github.com/aiscrm/redisgo
 
-require github.com/gomodule/redigo v2.0.0+incompatible
+go 1.18
+
+require (
+	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/gomodule/redigo v2.0.0+incompatible
+)
+
+require github.com/yuin/gopher-lua v1.1.1 // indirect
@@ -1,6 +1,10 @@
 package redisgo
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/gomodule/redigo/redis"
 )
 
@@ -23,3 +27,44 @@ func String(reply interface{}, err error) (string, error) {
 func Bool(reply interface{}, err error) (bool, error) {
 	return redis.Bool(reply, err)
 }
+
+// DumpReply renders a command reply the way redis-cli prints it, for debugging
+// replies of raw Do calls: bulk strings are quoted, status replies are printed
+// as is, and nested arrays are numbered and indented.
+func DumpReply(reply interface{}) string {
+	switch v := reply.(type) {
+	case nil:
+		return "(nil)"
+	case int64:
+		return "(integer) " + strconv.FormatInt(v, 10)
+	case []byte:
+		return strconv.Quote(string(v))
+	case string:
+		return v
+	case redis.Error:
+		return "(error) " + string(v)
+	case []interface{}:
+		if len(v) == 0 {
+			return "(empty array)"
+		}
+		width := len(strconv.Itoa(len(v)))
+		var b strings.Builder
+		for i, item := range v {
+			index := fmt.Sprintf("%*d) ", width, i+1)
+			for j, line := range strings.Split(DumpReply(item), "\n") {
+				if i > 0 || j > 0 {
+					b.WriteByte('\n')
+				}
+				if j == 0 {
+					b.WriteString(index)
+				} else {
+					b.WriteString(strings.Repeat(" ", len(index)))
+				}
+				b.WriteString(line)
+			}
+		}
+		return b.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,28 @@
+package redisgo
+
+import (
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestDumpReply(t *testing.T) {
+	Equal(t, "(nil)", DumpReply(nil))
+	Equal(t, "OK", DumpReply("OK"))
+	Equal(t, "(integer) 3", DumpReply(int64(3)))
+	Equal(t, `"line\n"`, DumpReply([]byte("line\n")))
+	Equal(t, "(error) ERR unknown", DumpReply(redis.Error("ERR unknown")))
+	Equal(t, "(empty array)", DumpReply([]interface{}{}))
+	reply := []interface{}{
+		[]byte("a"),
+		[]interface{}{[]byte("b"), int64(1)},
+		nil,
+	}
+	Equal(t, "1) \"a\"\n2) 1) \"b\"\n   2) (integer) 1\n3) (nil)", DumpReply(reply))
+
+	c := getCacher()
+	NoError(t, c.Set("name", "corel", 10))
+	name, err := c.Do("GET", c.Key("name"))
+	NoError(t, err)
+	Equal(t, `"corel"`, DumpReply(name))
+}
@@ -0,0 +1,51 @@
+package redisgo
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// defaultInvalidateChannel InvalidateBroadcast 和 ListenInvalidations 默认使用的频道
+const defaultInvalidateChannel = "redisgo:invalidate"
+
+// errTrackingDisabled 没有开启本地缓存时调用 ListenInvalidations 返回的错误
+var errTrackingDisabled = errors.New("redisgo: ListenInvalidations requires Options.Tracking")
+
+// InvalidateBroadcast 将键名发布到 Options.InvalidateChannel 频道，所有调用了 ListenInvalidations 的进程收到后会删除本地缓存中对应的键。
+// 键名会加上前缀，消息内容为完整键名的JSON数组。
+// 适用于写入redis之外的数据源后，需要让各个节点的本地缓存失效的场景；直接修改redis中的键时，CLIENT TRACKING 已经会通知失效。
+func (c *Cacher) InvalidateBroadcast(keys ...string) error {
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		if key == "" {
+			return ErrEmptyKey
+		}
+		fullKeys[i] = c.getKey(key)
+	}
+	data, err := json.Marshal(fullKeys)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("PUBLISH", c.invalidateChannel, data)
+	return err
+}
+
+// ListenInvalidations 在后台订阅 Options.InvalidateChannel 频道，收到 InvalidateBroadcast 发布的键名后删除本地缓存中对应的键。
+// 需要开启 Options.Tracking ，否则返回错误。断开重连期间的通知会丢失，所以重新订阅成功后会清空本地缓存。
+func (c *Cacher) ListenInvalidations() error {
+	t := c.state().tracking
+	if t == nil {
+		return errTrackingDisabled
+	}
+	onMessage := func(channel string, data []byte) error {
+		var keys []string
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			t.invalidate(key)
+		}
+		return nil
+	}
+	return c.SubscribeWithOptions(onMessage, SubscribeOptions{OnReconnect: t.flush}, c.invalidateChannel)
+}
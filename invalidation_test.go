@@ -0,0 +1,33 @@
+package redisgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvalidateBroadcast(t *testing.T) {
+	c := getCacher()
+	err := c.ListenInvalidations()
+	Equal(t, errTrackingDisabled, err)
+
+	// miniredis不支持 CLIENT TRACKING ，这里直接给副本构造本地缓存，连接池新建的连接不会开启跟踪
+	listener := c.WithPrefix("zengate_")
+	local := &tracking{
+		values:  map[string]interface{}{"zengate_name": "corel", "zengate_age": 23},
+		loading: make(map[string]uint64),
+	}
+	listener.conns = &connHolder{state: &connState{pool: c.state().pool, dial: c.state().dial, tracking: local}}
+	err = listener.ListenInvalidations()
+	NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	err = c.InvalidateBroadcast("name")
+	NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	local.mu.Lock()
+	_, nameCached := local.values["zengate_name"]
+	_, ageCached := local.values["zengate_age"]
+	local.mu.Unlock()
+	Equal(t, false, nameCached)
+	Equal(t, true, ageCached)
+}
@@ -0,0 +1,110 @@
+package redisgo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Queue 基于redis列表的泛型先进先出队列，元素从列表尾部（右边）加入，从头部（左边）取出。
+// 元素的序列化与 TypedCache 相同。
+// Example:
+//
+// ```golang
+// jobs := redisgo.NewQueue[Job](c, "jobs")
+// err := jobs.Push(Job{ID: 1})
+// job, err := jobs.BPop(5 * time.Second)
+// ```
+type Queue[T any] struct {
+	tc  *TypedCache[T]
+	key string
+}
+
+// NewQueue 使用Cacher创建元素类型为T的队列，key为列表的键名（会加上Cacher的前缀）
+func NewQueue[T any](c *Cacher, key string) *Queue[T] {
+	return &Queue[T]{tc: NewTypedCache[T](c), key: key}
+}
+
+// Push 将元素加入队列尾部
+func (q *Queue[T]) Push(v T) error {
+	return q.tc.RPush(q.key, v)
+}
+
+// Pop 从队列头部取出一个元素，队列为空时返回 ErrNil
+func (q *Queue[T]) Pop() (T, error) {
+	return q.tc.LPop(q.key)
+}
+
+// BPop 从队列头部取出一个元素，队列为空时阻塞等待，超时返回 ErrNil 。
+// timeout 向上取整到秒，值为0时表示一直等待。
+func (q *Queue[T]) BPop(timeout time.Duration) (T, error) {
+	return q.tc.decode(q.blpop(context.Background(), timeout))
+}
+
+// BPopContext 从队列头部取出一个元素，队列为空时阻塞等待，直到 ctx 取消或超时，此时返回 ctx.Err() 。
+// 每次 BLPOP 阻塞1秒，所以返回的时间可能比 ctx 的截止时间晚不到1秒；ctx 结束时已经取出了元素则返回该元素，不会丢失。
+func (q *Queue[T]) BPopContext(ctx context.Context) (T, error) {
+	for {
+		// BLPOP 的超时时间只能是整数秒，连接的读超时按它设置而不是 ctx 的截止时间，
+		// 以免服务端已经取出元素、回复还没有读到时连接先超时，导致元素丢失
+		readCtx, cancel := context.WithTimeout(withoutDeadline{ctx}, time.Second+blockingReadMargin)
+		reply, err := q.blpop(readCtx, time.Second)
+		cancel()
+		if reply != nil {
+			return q.tc.decode(reply, err)
+		}
+		if ctx.Err() != nil {
+			var zero T
+			return zero, ctx.Err()
+		}
+		if err == nil {
+			continue
+		}
+		return q.tc.decode(reply, err)
+	}
+}
+
+// blockingReadMargin 阻塞命令的读超时比服务端的超时时间多出的时长，用于容纳网络延迟
+const blockingReadMargin = 500 * time.Millisecond
+
+// withoutDeadline 保留 ctx 中的值（比如 OnCommand 和中间件使用的追踪信息），但不继承它的截止时间和取消
+type withoutDeadline struct {
+	context.Context
+}
+
+func (withoutDeadline) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (withoutDeadline) Done() <-chan struct{}       { return nil }
+func (withoutDeadline) Err() error                  { return nil }
+
+// Len 返回队列中的元素数量
+func (q *Queue[T]) Len() (int64, error) {
+	if q.key == "" {
+		return 0, ErrEmptyKey
+	}
+	return Int64(q.tc.c.Do("LLEN", q.tc.c.getKey(q.key)))
+}
+
+// blpop 执行 BLPOP 并返回取出的元素，超时返回nil
+func (q *Queue[T]) blpop(ctx context.Context, timeout time.Duration) (interface{}, error) {
+	if q.key == "" {
+		return nil, ErrEmptyKey
+	}
+	seconds := int64(math.Ceil(timeout.Seconds()))
+	if timeout > 0 && seconds == 0 {
+		seconds = 1
+	}
+	values, err := redis.Values(q.tc.c.DoContext(ctx, "BLPOP", q.tc.c.getKey(q.key), seconds))
+	if err == ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 2 {
+		return nil, fmt.Errorf("%w: unexpected number of values, got %d", ErrUnexpectedReply, len(values))
+	}
+	return values[1], nil
+}
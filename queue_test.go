@@ -0,0 +1,71 @@
+package redisgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+func TestQueue(t *testing.T) {
+	c := getCacher()
+	c.Del("queue_users")
+
+	users := NewQueue[User](c, "queue_users")
+	err := users.Push(User{Name: "corel", Age: 23})
+	NoError(t, err)
+	err = users.Push(User{Name: "alice", Age: 18})
+	NoError(t, err)
+	n, err := users.Len()
+	NoError(t, err)
+	Equal(t, int64(2), n)
+
+	user, err := users.Pop()
+	NoError(t, err)
+	Equal(t, User{Name: "corel", Age: 23}, user)
+	user, err = users.BPop(time.Second)
+	NoError(t, err)
+	Equal(t, User{Name: "alice", Age: 18}, user)
+
+	_, err = users.Pop()
+	Equal(t, true, errors.Is(err, ErrNil))
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		users.Push(User{Name: "bob", Age: 30})
+	}()
+	user, err = users.BPopContext(context.Background())
+	NoError(t, err)
+	Equal(t, User{Name: "bob", Age: 30}, user)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = users.BPopContext(ctx)
+	Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestQueueBPopContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 模拟 BLPOP 取出元素的同时 ctx 结束
+	c, _ := getHookedCacher(t, Options{Prefix: "app_"}, func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool {
+		if cmd == "BLPOP" {
+			cancel()
+		}
+		return false
+	})
+	jobs := NewQueue[string](c, "jobs")
+	NoError(t, jobs.Push("job1"))
+
+	job, err := jobs.BPopContext(ctx)
+	NoError(t, err)
+	Equal(t, "job1", job)
+	n, err := jobs.Len()
+	NoError(t, err)
+	Equal(t, int64(0), n)
+	_, err = jobs.BPopContext(ctx)
+	Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,45 @@
+package redisgo
+
+import (
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// leakyBucketScript 按经过的时间漏掉桶中的水，再尝试加入一个请求。使用redis服务的 TIME 作为时间，
+// redis 5.0以前的版本在脚本中调用 TIME 等随机命令后写入数据需要先调用 replicate_commands
+var leakyBucketScript = redis.NewScript(1, `
+redis.replicate_commands()
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local bucket = redis.call("HMGET", KEYS[1], "level", "ts")
+local level = tonumber(bucket[1]) or 0
+local last = tonumber(bucket[2]) or now
+if now > last then
+	level = math.max(0, level - (now - last) * rate)
+end
+local allowed = 0
+if level + 1 <= capacity then
+	level = level + 1
+	allowed = 1
+end
+redis.call("HMSET", KEYS[1], "level", tostring(level), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(level / rate * 1000) + 1000)
+return allowed`)
+
+// LeakyBucket 使用漏桶算法限流：桶的容量为 capacity 个请求，桶中的请求以每秒 leakRatePerSec 个的速度漏出，
+// 新请求在桶没有满时放入桶中并返回 true ，否则返回 false 。与固定窗口计数相比，请求被平滑地限制在 leakRatePerSec 的速率，
+// 同时允许最多 capacity 个请求的突发。
+// 桶保存在键 key 的哈希表中：字段 level 为当前桶中的请求数（小数），字段 ts 为上次更新的时间（unix时间戳，单位为秒，精度为微秒）。
+// 桶漏空后再过1秒过期删除。时间使用redis服务的 TIME ，不受各个客户端时钟偏差的影响。使用Lua脚本原子地执行。
+func (c *Cacher) LeakyBucket(key string, capacity int, leakRatePerSec float64) (allowed bool, err error) {
+	if capacity <= 0 {
+		return false, fmt.Errorf("redisgo: LeakyBucket: capacity must be positive, got %d", capacity)
+	}
+	if leakRatePerSec <= 0 {
+		return false, fmt.Errorf("redisgo: LeakyBucket: leakRatePerSec must be positive, got %v", leakRatePerSec)
+	}
+	return Bool(c.Eval(leakyBucketScript, []string{key}, capacity, leakRatePerSec))
+}
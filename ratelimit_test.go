@@ -0,0 +1,33 @@
+package redisgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeakyBucket(t *testing.T) {
+	c := getCacher()
+	c.Del("bucket")
+	for i := 0; i < 3; i++ {
+		allowed, err := c.LeakyBucket("bucket", 3, 10)
+		NoError(t, err)
+		Equal(t, true, allowed)
+	}
+	allowed, err := c.LeakyBucket("bucket", 3, 10)
+	NoError(t, err)
+	Equal(t, false, allowed)
+
+	// 每秒漏出10个请求，150毫秒后可以再放入一个
+	time.Sleep(150 * time.Millisecond)
+	allowed, err = c.LeakyBucket("bucket", 3, 10)
+	NoError(t, err)
+	Equal(t, true, allowed)
+	allowed, err = c.LeakyBucket("bucket", 3, 10)
+	NoError(t, err)
+	Equal(t, false, allowed)
+
+	_, err = c.LeakyBucket("bucket", 0, 10)
+	Error(t, err)
+	_, err = c.LeakyBucket("bucket", 3, 0)
+	Error(t, err)
+}
@@ -1,14 +1,34 @@
 // Package redisgo 基于github.com/gomodule/redigo，封装了redis缓存的实现。
 // 使用时，可以参考 http://redisdoc.com/ 和 http://www.runoob.com/redis/ 中对redis命令及用法的讲解。
 // 对于没有封装的命令，也可以参考这里的实现方法，直接调用 `Do` 方法直接调用redis命令。
+//
+// 键名和值都是二进制安全的：Go的string可以保存任意字节（包括\x00和非UTF-8的字节），键名与前缀按字节拼接，
+// redigo按原始字节发送，所以哈希值等二进制键名可以直接转换为string使用，比如 c.Set(string(sum[:]), val, 0)。
+// 字符串和[]byte类型的值原样保存，读取时使用 Scan 或 TypedCache[[]byte] 得到原始字节。
+// 注意：早期版本的Set等方法会把[]byte当作对象序列化（json序列化为带引号的base64字符串），升级后写入的是原始字节，两种格式不能互相读取。
+// 升级前写入的[]byte值需要使用 GetObject 反序列化到*[]byte，直到被重新写入为止。
+//
+// 前缀只加在键名上：作为键名的参数会加上前缀，返回键名的方法（比如 ScanPage）会去掉前缀；
+// 集合的成员、哈希的字段和值等数据原样保存和返回，即使它们的内容是键名。用集合或哈希保存键名作为二级索引时，
+// 建议保存不含前缀的逻辑键名，这样可以直接传给其他方法；保存的是完整键名（比如来自 Key 或 Do 的结果）时，使用 StripPrefix 转换为逻辑键名。
 package redisgo
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
 	"os"
 	"os/signal"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -18,25 +38,70 @@ import (
 )
 
 // Cacher 先构建一个Cacher实例，然后将配置参数传入该实例的StartAndGC方法来初始化实例和程序进程退出后的清理工作。
+// 前缀、序列化方法等字段只在初始化时设置，之后不再修改（WithPrefix 返回的是副本），可以在多个goroutine中并发读取；
+// 会被 Reset 替换的连接池等状态保存在 connHolder 中，通过读写锁访问。
 type Cacher struct {
-	pool      *redis.Pool
-	prefix    string
-	marshal   func(v interface{}) ([]byte, error)
-	unmarshal func(data []byte, v interface{}) error
-}
+	conns             *connHolder
+	prefix            string
+	prefixSeparator   string
+	marshal           func(v interface{}) ([]byte, error)
+	unmarshal         func(data []byte, v interface{}) error
+	jsonUnmarshal     bool // 是否使用默认的 json.Unmarshal
+	formatTag         byte
+	unmarshalers      map[byte]func(data []byte, v interface{}) error
+	compress          bool
+	compressMinBytes  int
+	onCommand         func(ctx context.Context, commandName string, duration time.Duration, err error)
+	slidingExpire     int64
+	onPoolWait        func(d time.Duration)
+	middleware        []func(next DoFunc) DoFunc
+	breaker           *circuitBreaker
+	invalidateChannel string
+	keyRouter         func(key string) (prefix string, db int)
+	ttlJitter         float64
+	existsFreshGrace  time.Duration
+	commandTimeout    time.Duration
+}
+
+// DoFunc 执行一条redis命令，用于 Options.Middleware
+type DoFunc func(commandName string, args ...interface{}) (interface{}, error)
 
 // Options redis配置参数
 type Options struct {
-	Network     string                                 // 通讯协议，默认为 tcp
-	Addr        string                                 // redis服务的地址，默认为 127.0.0.1:6379
-	Password    string                                 // redis鉴权密码
-	Db          int                                    // 数据库
-	MaxActive   int                                    // 最大活动连接数，值为0时表示不限制
-	MaxIdle     int                                    // 最大空闲连接数
-	IdleTimeout int                                    // 空闲连接的超时时间，超过该时间则关闭连接。单位为秒。默认值是5分钟。值为0时表示不关闭空闲连接。此值应该总是大于redis服务的超时时间。
-	Prefix      string                                 // 键名前缀
-	Marshal     func(v interface{}) ([]byte, error)    // 数据序列化方法，默认使用json.Marshal序列化
-	Unmarshal   func(data []byte, v interface{}) error // 数据反序列化方法，默认使用json.Unmarshal序列化
+	Network                 string                                          // 通讯协议，默认为 tcp
+	Addr                    string                                          // redis服务的地址，默认为 127.0.0.1:6379
+	Username                string                                          // redis 6 ACL的用户名，设置后使用 AUTH username password 鉴权，为空时使用 AUTH password
+	Password                string                                          // redis鉴权密码
+	Db                      int                                             // 数据库
+	MaxActive               int                                             // 最大活动连接数，值为0时表示不限制
+	MaxIdle                 int                                             // 最大空闲连接数
+	Wait                    bool                                            // 连接数达到 MaxActive 时，是否等待其他连接释放。默认为false，直接返回错误
+	IdleTimeout             int                                             // 空闲连接的超时时间，超过该时间则关闭连接。单位为秒。默认值是5分钟。值为0时表示不关闭空闲连接。此值应该总是大于redis服务的超时时间。
+	Prefix                  string                                          // 键名前缀
+	PrefixSeparator         string                                          // 键名前缀与键名之间的分隔符，比如 ":" ，默认为空，即前缀与键名直接拼接。前缀为空时不加分隔符
+	Marshal                 func(v interface{}) ([]byte, error)             // 数据序列化方法，默认使用json.Marshal序列化
+	Unmarshal               func(data []byte, v interface{}) error          // 数据反序列化方法，默认使用json.Unmarshal序列化
+	Tracking                bool                                            // 是否开启客户端缓存（CLIENT TRACKING），开启后Get优先读取本地缓存，Prefix 下的键被修改时由redis广播通知失效，WithConn 中的写入只能等待通知
+	ClientName              string                                          // 连接名称，设置后每个连接都会执行 CLIENT SETNAME，便于在 CLIENT LIST 中识别。名称中不能包含空格
+	FormatTag               byte                                            // 序列化格式标记，默认为0表示不加标记。不为0时序列化后的数据前会加上这个字节，反序列化时根据第一个字节选择反序列化方法，以便更换序列化方法时旧数据仍然可以读取。应该使用小于0x20等不会出现在序列化数据开头的字节
+	Unmarshalers            map[byte]func(data []byte, v interface{}) error // 其他格式标记对应的反序列化方法，仅在设置了 FormatTag 时生效。键为0的方法用于读取没有格式标记的旧数据，未设置时使用 Unmarshal
+	Compress                bool                                            // 是否使用gzip压缩序列化后的对象，只对需要序列化的对象生效，字符串和数字等基础类型不会压缩。没有压缩的旧数据仍然可以正常读取
+	CompressMinBytes        int                                             // 开启压缩时，序列化后的数据达到该字节数才压缩，默认为1024
+	PingOnBorrowInterval    time.Duration                                   // 从连接池获取连接时，连接空闲超过该时长才执行PING检查连接是否可用，减少每次执行命令的网络往返。默认为1分钟，值为负数时表示每次获取连接都检查
+	SlidingExpire           int64                                           // 大于0时，Get及其工具方法在读取成功的同时把键的有效期重置为该秒数，适用于会话等需要滑动过期的场景
+	InvalidateChannel       string                                          // InvalidateBroadcast 和 ListenInvalidations 使用的频道，默认为 redisgo:invalidate 。不会加上键名前缀，使用同一个redis的不同服务可以设置不同的频道
+	CommandAliases          map[string]string                               // 服务端被重命名的命令（rename-command），键为原命令名（不区分大小写），值为重命名后的名称，比如 {"FLUSHDB": "f1u5hdb"}。所有命令都会按这里的映射发送，包括管道、Lua脚本和订阅
+	DisableSignalClose      bool                                            // 是否不注册信号处理。默认在收到 SIGINT 或 SIGTERM 时关闭连接池并调用 os.Exit(0) 退出进程；作为库嵌入到其他程序中时应该设置为true，由程序自己处理信号并调用 Close
+	CircuitBreakerThreshold int                                             // 大于0时开启熔断：连续出现该次数的连接失败（网络错误、网络超时等，不包括redis返回的错误、连接池耗尽和 ctx 到期）后，命令直接返回 ErrCircuitOpen ，不再等待连接超时
+	CircuitBreakerCooldown  time.Duration                                   // 熔断后经过该时长放行一条命令探测redis是否恢复，成功后恢复正常，默认为10秒
+	TTLJitter               float64                                         // 大于0时，Set 和 SetAndPublish 的有效期在 expire*(1±TTLJitter) 范围内随机调整（至少为1秒），避免同时写入的大量键同时过期。比如 0.1 表示±10%，应该小于1。只对大于0的有效期生效
+	ExistsFreshGrace        time.Duration                                   // ExistsFresh 的宽限时长，剩余有效期不超过该时长的键视为已经不存在，默认为0
+	CommandTimeout          time.Duration                                   // 大于0时，通过 Do 执行的命令（包括其他方法内部执行的命令）使用 DoWithTimeout ，等待回复超过该时长返回超时错误。ctx 设置了截止时间时以 ctx 为准；BLPOP 等阻塞命令、管道、Lua脚本和 WithConn 的连接不受影响。默认为0，表示不限制
+
+	OnCommand  func(ctx context.Context, commandName string, duration time.Duration, err error) // 每次通过 Do 或 DoContext 执行命令后的回调，可用于统计耗时和链路追踪。通过 Do 执行时 ctx 为 context.Background()
+	OnPoolWait func(d time.Duration)                                                            // 每次从连接池获取连接后的回调，d 为获取连接的耗时。连接数达到 MaxActive 且 Wait 为 true 时，d 包含等待其他连接释放的时间，可用于发现连接池耗尽
+	Middleware []func(next DoFunc) DoFunc                                                       // 包装每条命令执行的中间件，可用于日志、熔断、限流等。Middleware[0] 在最外层，最先收到命令；最后一个中间件的 next 执行命令。通过 Do 执行的命令（包括其他方法内部执行的命令）都会经过中间件，管道、Lua脚本和 WithConn 的连接除外
+	KeyRouter  func(key string) (prefix string, db int)                                         // 按键名选择前缀和数据库，用于多租户隔离。设置后所有以键名为参数的方法使用返回的 prefix 代替 Prefix（同样会加上 PrefixSeparator ）；db 只在通过 Route 获取的Cacher上生效，详见 Route 。ScanPage 等基于 SCAN 的方法不支持 KeyRouter
 }
 
 // New 根据配置参数创建redis工具实例
@@ -46,167 +111,1686 @@ func New(options Options) (*Cacher, error) {
 	return r, err
 }
 
-// StartAndGC 使用 Options 初始化redis，并在程序进程退出时关闭连接池。
+// StartAndGC 使用 Options 初始化redis，并在程序进程退出时关闭连接池（设置了 Options.DisableSignalClose 时除外）。
+// 初始化时会连接redis服务并执行PING，连接或鉴权失败时返回错误。
 func (c *Cacher) StartAndGC(options interface{}) error {
 	switch opts := options.(type) {
 	case Options:
-		if opts.Network == "" {
-			opts.Network = "tcp"
+		if strings.ContainsAny(opts.Prefix+opts.PrefixSeparator, globChars) {
+			return fmt.Errorf("redisgo: prefix %q contains glob characters %q", opts.Prefix+opts.PrefixSeparator, globChars)
+		}
+		c.prefixSeparator = opts.PrefixSeparator
+		c.prefix = c.joinPrefix(opts.Prefix)
+		c.marshal = opts.Marshal
+		if c.marshal == nil {
+			c.marshal = json.Marshal
+		}
+		c.unmarshal = opts.Unmarshal
+		if c.unmarshal == nil {
+			c.unmarshal = json.Unmarshal
+			c.jsonUnmarshal = true
+		}
+		c.formatTag = opts.FormatTag
+		c.unmarshalers = opts.Unmarshalers
+		c.onCommand = opts.OnCommand
+		c.slidingExpire = opts.SlidingExpire
+		c.onPoolWait = opts.OnPoolWait
+		c.middleware = opts.Middleware
+		c.keyRouter = opts.KeyRouter
+		if opts.TTLJitter < 0 || opts.TTLJitter >= 1 {
+			return fmt.Errorf("redisgo: TTLJitter must be in [0, 1), got %v", opts.TTLJitter)
+		}
+		c.ttlJitter = opts.TTLJitter
+		c.existsFreshGrace = opts.ExistsFreshGrace
+		c.commandTimeout = opts.CommandTimeout
+		c.breaker = newCircuitBreaker(opts.CircuitBreakerThreshold, opts.CircuitBreakerCooldown)
+		c.invalidateChannel = opts.InvalidateChannel
+		if c.invalidateChannel == "" {
+			c.invalidateChannel = defaultInvalidateChannel
+		}
+		c.compress = opts.Compress
+		c.compressMinBytes = opts.CompressMinBytes
+		if c.compressMinBytes == 0 {
+			c.compressMinBytes = 1024
+		}
+		state, err := newConnState(opts)
+		if err != nil {
+			return err
+		}
+		c.conns = &connHolder{state: state}
+		if !opts.DisableSignalClose {
+			c.closePool()
+		}
+		return nil
+	default:
+		return ErrUnsupportedOptions
+	}
+}
+
+// connState 连接池以及建立连接的方法，Reset 时整体替换。创建后不再修改，替换时使用新的 connState 。
+type connState struct {
+	pool          *redis.Pool
+	dial          func() (redis.Conn, error)
+	setup         func(conn redis.Conn) error // 设置连接的鉴权、数据库和连接名称，RESET 后需要重新设置
+	tracking      *tracking
+	serverVersion string // 初始化时从 INFO server 获取的redis服务版本号，Reset 后可能连接到其他版本的服务
+	opts          Options
+	dbMu          sync.Mutex
+	dbStates      map[int]*connState // Route 使用的其他数据库的连接状态，按需创建
+}
+
+// connHolder 保存当前的连接状态。WithPrefix 等方法返回的Cacher共用同一个 connHolder ，Reset 替换连接池后对它们同时生效。
+type connHolder struct {
+	mu    sync.RWMutex
+	state *connState
+}
+
+// newConnState 根据 Options 中与连接相关的配置创建连接池，并执行PING检查配置是否正确
+func newConnState(opts Options) (*connState, error) {
+	if opts.Network == "" {
+		opts.Network = "tcp"
+	}
+	if opts.Addr == "" {
+		opts.Addr = "127.0.0.1:6379"
+	}
+	if opts.MaxIdle == 0 {
+		opts.MaxIdle = 3
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = 300
+	}
+	if opts.PingOnBorrowInterval == 0 {
+		opts.PingOnBorrowInterval = time.Minute
+	}
+	aliases := newAliases(opts.CommandAliases)
+	// setup 设置新建立的连接（或 RESET 后的连接）的鉴权、数据库和连接名称
+	setup := func(conn redis.Conn) error {
+		if opts.Username != "" {
+			if _, err := conn.Do("AUTH", opts.Username, opts.Password); err != nil {
+				return err
+			}
+		} else if opts.Password != "" {
+			if _, err := conn.Do("AUTH", opts.Password); err != nil {
+				return err
+			}
+		}
+		if _, err := conn.Do("SELECT", opts.Db); err != nil {
+			return err
+		}
+		if opts.ClientName != "" {
+			if _, err := conn.Do("CLIENT", "SETNAME", opts.ClientName); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	dial := func() (redis.Conn, error) {
+		conn, err := redis.Dial(opts.Network, opts.Addr)
+		if err != nil {
+			return nil, err
+		}
+		if len(aliases) > 0 {
+			conn = aliasConn{Conn: conn, aliases: aliases}
+		}
+		if err := setup(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	var t *tracking
+	if opts.Tracking {
+		var err error
+		t, err = newTracking(dial, opts.Prefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pool := &redis.Pool{
+		MaxActive:   opts.MaxActive,
+		MaxIdle:     opts.MaxIdle,
+		Wait:        opts.Wait,
+		IdleTimeout: time.Duration(opts.IdleTimeout) * time.Second,
+
+		Dial: dial,
+
+		TestOnBorrow: func(conn redis.Conn, lastUsed time.Time) error {
+			// lastUsed 为连接放回连接池的时间，最近使用过的连接不再检查
+			if time.Since(lastUsed) < opts.PingOnBorrowInterval {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}
+
+	// 连接池是在执行命令时才建立连接的，这里先获取一个连接检查配置是否正确（地址、密码、数据库等），
+	// 以便在初始化时就发现错误，而不是等到第一次执行命令时
+	conn := pool.Get()
+	_, err := conn.Do("PING")
+	var version string
+	if err == nil {
+		version = probeServerVersion(conn)
+	}
+	conn.Close()
+	if err != nil {
+		pool.Close()
+		if t != nil {
+			t.close()
+		}
+		return nil, wrapError("PING", err)
+	}
+
+	return &connState{pool: pool, dial: dial, setup: setup, tracking: t, serverVersion: version, opts: opts}, nil
+}
+
+// Reset 使用新的 Options 重新建立连接池并替换当前的连接池，可以用于在运行时更换密码等配置，不需要重新创建Cacher。
+// 只替换与连接相关的配置：地址、鉴权、数据库、连接池参数、ClientName、CommandAliases 和 Tracking 等，
+// Prefix、序列化方法和回调等其他配置保持不变。新连接池PING失败时返回错误，继续使用原连接池。
+// 替换后关闭原连接池：原连接池的空闲连接立即关闭，正在执行命令的连接在命令执行完放回时关闭，不会中断这些命令。
+func (c *Cacher) Reset(options Options) error {
+	state, err := newConnState(options)
+	if err != nil {
+		return err
+	}
+	c.conns.mu.Lock()
+	old := c.conns.state
+	c.conns.state = state
+	c.conns.mu.Unlock()
+	return old.close()
+}
+
+// close 关闭连接池和客户端缓存的监听连接，以及 Route 创建的其他数据库的连接池
+func (s *connState) close() error {
+	s.dbMu.Lock()
+	for _, state := range s.dbStates {
+		state.close()
+	}
+	s.dbStates = nil
+	s.dbMu.Unlock()
+	if s.tracking != nil {
+		s.tracking.close()
+	}
+	return s.pool.Close()
+}
+
+// forDB 返回使用相同配置连接到数据库 db 的连接状态，第一次使用时创建并缓存
+func (s *connState) forDB(db int) (*connState, error) {
+	if db == s.opts.Db {
+		return s, nil
+	}
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
+	if state, ok := s.dbStates[db]; ok {
+		return state, nil
+	}
+	opts := s.opts
+	opts.Db = db
+	state, err := newConnState(opts)
+	if err != nil {
+		return nil, err
+	}
+	if s.dbStates == nil {
+		s.dbStates = make(map[int]*connState)
+	}
+	s.dbStates[db] = state
+	return state, nil
+}
+
+// state 返回当前的连接状态
+func (c *Cacher) state() *connState {
+	c.conns.mu.RLock()
+	defer c.conns.mu.RUnlock()
+	return c.conns.state
+}
+
+// WithPrefix 返回一个使用指定键名前缀的Cacher，与原Cacher共用连接池和其他配置，前缀后同样会加上 PrefixSeparator 。
+// 可以用来读写其他服务以不同前缀写入的键。
+func (c *Cacher) WithPrefix(prefix string) *Cacher {
+	cc := *c
+	cc.prefix = c.joinPrefix(prefix)
+	return &cc
+}
+
+// Route 返回使用 KeyRouter 为 key 选择的数据库的Cacher，没有设置 KeyRouter 或选择的是 Options.Db 时返回c本身。
+// 连接池只能连接到固定的数据库，所以每个数据库使用单独的连接池（与c的连接池配置相同），第一次使用时创建，c Close 或 Reset 时关闭，
+// 所以应该在每次使用时调用 Route ，不要长期保存返回的Cacher。返回的Cacher的方法仍然通过 KeyRouter 选择前缀，
+// 同一次操作涉及的多个键应该路由到同一个数据库。Do 的参数不会路由，与不加前缀相同。
+// Example:
+//
+// ```golang
+// tc, err := c.Route("tenant1:user:1")
+// err = tc.Set("tenant1:user:1", user, 0)
+// ```
+func (c *Cacher) Route(key string) (*Cacher, error) {
+	if c.keyRouter == nil {
+		return c, nil
+	}
+	_, db := c.keyRouter(key)
+	state, err := c.state().forDB(db)
+	if err != nil {
+		return nil, err
+	}
+	if state == c.state() {
+		return c, nil
+	}
+	cc := *c
+	cc.conns = &connHolder{state: state}
+	return &cc, nil
+}
+
+// NoPrefix 返回一个不使用键名前缀的Cacher，与原Cacher共用连接池和其他配置。
+// 所有以键名为参数的方法都会加上前缀，而 Do 的参数和发布订阅的频道名不会加前缀。
+func (c *Cacher) NoPrefix() *Cacher {
+	return c.WithPrefix("")
+}
+
+// Prefix 返回实际加在键名前的前缀（包含 PrefixSeparator ），包装Cacher的代码可以用它以相同的方式拼接键名
+func (c *Cacher) Prefix() string {
+	return c.prefix
+}
+
+// Marshaler 返回当前使用的序列化方法，即 Options.Marshal ，没有设置时为 json.Marshal 。
+// 返回的是原始的序列化方法，不包含 FormatTag 和压缩的处理。
+func (c *Cacher) Marshaler() func(v interface{}) ([]byte, error) {
+	return c.marshal
+}
+
+// Unmarshaler 返回当前使用的反序列化方法，即 Options.Unmarshal ，没有设置时为 json.Unmarshal 。
+func (c *Cacher) Unmarshaler() func(data []byte, v interface{}) error {
+	return c.unmarshal
+}
+
+// Do 执行redis命令并返回结果。执行时从连接池获取连接并在执行完命令后关闭连接。
+// 参数会原样传给redis，不会为键名加上前缀。
+func (c *Cacher) Do(commandName string, args ...interface{}) (reply interface{}, err error) {
+	return c.DoContext(context.Background(), commandName, args...)
+}
+
+// DoContext 与 Do 相同，ctx 用于控制获取连接的等待时间和命令执行的超时时间，并会传给 OnCommand 回调，便于接入链路追踪。
+func (c *Cacher) DoContext(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
+	start := time.Now()
+	if len(c.middleware) > 0 {
+		reply, err = c.chain(ctx)(commandName, args...)
+	} else {
+		reply, err = c.do(ctx, commandName, args...)
+	}
+	if c.onCommand != nil {
+		c.onCommand(ctx, commandName, time.Since(start), err)
+	}
+	return reply, err
+}
+
+// DoScanStruct 执行返回字段、值交替数组的命令（比如 HGETALL、CONFIG GET），并使用 redis.ScanStruct 将结果写入 dest 指向的结构体。
+// 与 Do 一样，参数会原样传给redis，不会为键名加上前缀。
+// Example:
+//
+// ```golang
+// var user User
+// err := c.DoScanStruct(&user, "HGETALL", "prefix:user:1")
+// ```
+func (c *Cacher) DoScanStruct(dest interface{}, commandName string, args ...interface{}) error {
+	values, err := redis.Values(c.Do(commandName, args...))
+	if err != nil {
+		return err
+	}
+	return wrapError(commandName, redis.ScanStruct(values, dest))
+}
+
+// DoMany 在同一个连接上使用管道执行多条命令，只需要一次网络往返，按顺序返回每条命令的结果。
+// commands 的每个元素为 {命令名, 参数1, 参数2, ...} ，与 Do 一样参数会原样传给redis，不会为键名加上前缀，也不会触发 OnCommand 回调。
+// 某条命令执行出错时不影响其他命令，该命令对应的结果为 error 类型的值，并返回第一个出错的命令的错误。
+// Example:
+//
+// ```golang
+// replies, err := c.DoMany([][]interface{}{
+// {"INCR", "prefix:visits"},
+// {"SET", "prefix:last", "corel"},
+// })
+// ```
+func (c *Cacher) DoMany(commands [][]interface{}) ([]interface{}, error) {
+	names := make([]string, len(commands))
+	for i, command := range commands {
+		if len(command) == 0 {
+			return nil, fmt.Errorf("redisgo: DoMany: empty command at index %d", i)
+		}
+		name, ok := command[0].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("redisgo: DoMany: invalid command name %v at index %d", command[0], i)
+		}
+		names[i] = name
+	}
+	if len(commands) == 0 {
+		return []interface{}{}, nil
+	}
+	defer func() {
+		for i, command := range commands {
+			c.evictLocal(names[i], command[1:]...)
+		}
+	}()
+	conn := c.getConn()
+	defer conn.Close()
+	for i, command := range commands {
+		if err := conn.Send(names[i], command[1:]...); err != nil {
+			return nil, wrapError(names[i], err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, wrapError(names[0], err)
+	}
+	replies, errs := receiveAll(conn, len(commands))
+	var err error
+	for i, e := range errs {
+		if e != nil {
+			e = wrapError(names[i], e)
+			if err == nil {
+				err = e
+			}
+			replies[i] = e
+		}
+	}
+	return replies, err
+}
+
+// Scan 使用与 GetObject 等方法相同的方式将 Do 返回的结果转换到 dest 中，dest 必须是指针。
+// 字符串、[]byte、数字和布尔类型直接转换（Set 等方法保存这些类型时没有序列化），其他类型使用配置的反序列化方法（包括格式标记和解压）。
+// 结果为数组时 dest 必须是切片的指针，数组的每个元素按上面的规则转换为切片的一个元素，支持嵌套的数组。
+// Example:
+//
+// ```golang
+// var users []User
+// reply, err := c.Do("MGET", "prefix:user:1", "prefix:user:2")
+// err = c.Scan(reply, err, &users)
+// ```
+func (c *Cacher) Scan(reply interface{}, err error, dest interface{}) error {
+	if err != nil {
+		return err
+	}
+	if values, ok := reply.([]interface{}); ok {
+		v := reflect.ValueOf(dest)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+			return fmt.Errorf("redisgo: Scan: array reply requires a pointer to slice, got %T", dest)
+		}
+		slice := reflect.MakeSlice(v.Elem().Type(), len(values), len(values))
+		for i, value := range values {
+			if err := c.Scan(value, nil, slice.Index(i).Addr().Interface()); err != nil {
+				return err
+			}
+		}
+		v.Elem().Set(slice)
+		return nil
+	}
+	switch p := dest.(type) {
+	case *string:
+		*p, err = String(reply, nil)
+	case *[]byte:
+		*p, err = redis.Bytes(reply, nil)
+	case *bool:
+		*p, err = Bool(reply, nil)
+	case *int, *int8, *int16, *int32, *int64:
+		var n int64
+		if n, err = redis.Int64(reply, nil); err == nil {
+			v := reflect.ValueOf(p).Elem()
+			if v.OverflowInt(n) {
+				return fmt.Errorf("redisgo: Scan: value %d overflows %s", n, v.Type())
+			}
+			v.SetInt(n)
+		}
+	case *uint:
+		var n uint64
+		if n, err = redis.Uint64(reply, nil); err == nil {
+			v := reflect.ValueOf(p).Elem()
+			if v.OverflowUint(n) {
+				return fmt.Errorf("redisgo: Scan: value %d overflows %s", n, v.Type())
+			}
+			v.SetUint(n)
+		}
+	case *float32, *float64:
+		var f float64
+		if f, err = redis.Float64(reply, nil); err == nil {
+			reflect.ValueOf(p).Elem().SetFloat(f)
+		}
+	default:
+		err = c.decode(reply, nil, dest)
+	}
+	return err
+}
+
+// chain 使用中间件包装命令的执行，Middleware[0] 在最外层
+func (c *Cacher) chain(ctx context.Context) DoFunc {
+	next := DoFunc(func(commandName string, args ...interface{}) (interface{}, error) {
+		return c.do(ctx, commandName, args...)
+	})
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		next = c.middleware[i](next)
+	}
+	return next
+}
+
+// blockingCommands 可能长时间阻塞等待数据的命令，不使用 Options.CommandTimeout
+var blockingCommands = map[string]bool{
+	"BLPOP":      true,
+	"BRPOP":      true,
+	"BRPOPLPUSH": true,
+	"BLMOVE":     true,
+	"BLMPOP":     true,
+	"BZPOPMIN":   true,
+	"BZPOPMAX":   true,
+	"BZMPOP":     true,
+	"XREAD":      true,
+	"XREADGROUP": true,
+	"WAIT":       true,
+}
+
+// do 从连接池获取连接并执行命令，ctx 设置了截止时间或配置了 CommandTimeout 时使用 DoWithTimeout 执行
+func (c *Cacher) do(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
+	if c.breaker != nil {
+		if err := c.breaker.allow(); err != nil {
+			return nil, wrapError(commandName, err)
+		}
+		defer func() { c.breaker.record(ctx, err) }()
+	}
+	conn, err := c.getConnContext(ctx)
+	if err != nil {
+		return nil, wrapError(commandName, err)
+	}
+	// 放回连接之后再删除本地缓存，evictLocal 获取连接状态时不能占用连接
+	defer c.evictLocal(commandName, args...)
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		reply, err = redis.DoWithTimeout(conn, time.Until(deadline), commandName, args...)
+	} else if c.commandTimeout > 0 && !blockingCommands[strings.ToUpper(commandName)] {
+		reply, err = redis.DoWithTimeout(conn, c.commandTimeout, commandName, args...)
+	} else {
+		reply, err = conn.Do(commandName, args...)
+	}
+	return reply, wrapError(commandName, err)
+}
+
+// Close 关闭连接池，之后执行命令都会返回 ErrClosed 。
+func (c *Cacher) Close() error {
+	return c.state().close()
+}
+
+// Stats 返回连接池的统计信息
+func (c *Cacher) Stats() redis.PoolStats {
+	return c.state().pool.Stats()
+}
+
+// getConn 从连接池获取连接，并通过 OnPoolWait 回调获取连接的等待时长
+func (c *Cacher) getConn() redis.Conn {
+	start := time.Now()
+	// 持有读锁直到获取到连接，以免 Reset 在获取连接前关闭了连接池
+	c.conns.mu.RLock()
+	conn := c.conns.state.pool.Get()
+	c.conns.mu.RUnlock()
+	if c.onPoolWait != nil {
+		c.onPoolWait(time.Since(start))
+	}
+	return conn
+}
+
+// getConnContext 与 getConn 相同，ctx 用于控制等待连接的时长
+func (c *Cacher) getConnContext(ctx context.Context) (redis.Conn, error) {
+	start := time.Now()
+	c.conns.mu.RLock()
+	conn, err := c.conns.state.pool.GetContext(ctx)
+	c.conns.mu.RUnlock()
+	if c.onPoolWait != nil {
+		c.onPoolWait(time.Since(start))
+	}
+	return conn, err
+}
+
+// receiveAll 读取管道中 n 条命令的回复，返回每条命令的回复和错误（redis返回的错误也在 errs 中）。
+// 前面的命令出错时也会读取所有回复，保证连接放回连接池时是干净的。
+func receiveAll(conn redis.Conn, n int) (replies []interface{}, errs []error) {
+	replies = make([]interface{}, n)
+	errs = make([]error, n)
+	for i := range replies {
+		replies[i], errs[i] = conn.Receive()
+	}
+	return replies, errs
+}
+
+// Get 获取键值。一般不直接使用该值，而是配合下面的工具类方法获取具体类型的值，或者直接使用github.com/gomodule/redigo/redis包的工具方法。
+// 开启了 Tracking 时，优先从本地缓存读取；设置了 SlidingExpire 时，读取的同时重置有效期，不使用本地缓存。
+func (c *Cacher) Get(key string) (interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if c.slidingExpire > 0 {
+		return c.getSliding(key)
+	}
+	if t := c.state().tracking; t != nil {
+		return t.get(c.getKey(key), func(key string) (interface{}, error) {
+			return c.Do("GET", key)
+		})
+	}
+	return c.Do("GET", c.getKey(key))
+}
+
+// getSliding 获取键值并将有效期重置为 SlidingExpire 。redis 6.2及以上版本使用 GETEX 原子地完成，
+// 低版本使用 GET 和 EXPIRE 两条命令，多一次网络往返。
+func (c *Cacher) getSliding(key string) (interface{}, error) {
+	if c.Supports("GETEX") {
+		return c.Do("GETEX", c.getKey(key), "EX", c.slidingExpire)
+	}
+	reply, err := c.Do("GET", c.getKey(key))
+	if err != nil || reply == nil {
+		return reply, err
+	}
+	if _, err := c.Do("EXPIRE", c.getKey(key), c.slidingExpire); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// GetString 获取string类型的键值
+func (c *Cacher) GetString(key string) (string, error) {
+	return String(c.Get(key))
+}
+
+// GetInt 获取int类型的键值
+func (c *Cacher) GetInt(key string) (int, error) {
+	return Int(c.Get(key))
+}
+
+// GetInt64 获取int64类型的键值
+func (c *Cacher) GetInt64(key string) (int64, error) {
+	return Int64(c.Get(key))
+}
+
+// GetBool 获取bool类型的键值
+func (c *Cacher) GetBool(key string) (bool, error) {
+	return Bool(c.Get(key))
+}
+
+// GetStringOr 获取string类型的键值，出现任何错误时返回 def ，适用于尽力而为的非关键缓存。
+// 键不存在（缓存未命中）和redis不可用都会返回 def ，调用方无法区分；需要区分时使用 GetString 。
+// 错误虽然不会返回，但仍然会经过 OnCommand 回调和中间件，可以在其中记录日志。
+func (c *Cacher) GetStringOr(key, def string) string {
+	val, err := c.GetString(key)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// GetIntOr 获取int类型的键值，出现任何错误时返回 def ，说明见 GetStringOr
+func (c *Cacher) GetIntOr(key string, def int) int {
+	val, err := c.GetInt(key)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// GetInt64Or 获取int64类型的键值，出现任何错误时返回 def ，说明见 GetStringOr
+func (c *Cacher) GetInt64Or(key string, def int64) int64 {
+	val, err := c.GetInt64(key)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// GetBoolOr 获取bool类型的键值，出现任何错误时返回 def ，说明见 GetStringOr
+func (c *Cacher) GetBoolOr(key string, def bool) bool {
+	val, err := c.GetBool(key)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// GetObject 获取非基本类型stuct的键值。在实现上，使用json的Marshal和Unmarshal做序列化存取。
+func (c *Cacher) GetObject(key string, val interface{}) error {
+	reply, err := c.Get(key)
+	return c.decode(reply, err, val)
+}
+
+// GetObjectTTL 与 GetObject 相同，同时返回键的剩余有效期（秒），可以用来设置HTTP的 max-age 等。
+// GET 和 TTL 在同一个连接上使用管道执行，只需要一次网络往返。键不存在时返回 ErrNil ，ttl 为-2；键没有设置有效期时 ttl 为-1。
+// 不使用客户端缓存，也不会刷新 SlidingExpire 的有效期。
+func (c *Cacher) GetObjectTTL(key string, val interface{}) (ttl int64, err error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	conn := c.getConn()
+	defer conn.Close()
+	if err := conn.Send("GET", c.getKey(key)); err != nil {
+		return 0, wrapError("GET", err)
+	}
+	if err := conn.Send("TTL", c.getKey(key)); err != nil {
+		return 0, wrapError("TTL", err)
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, wrapError("GET", err)
+	}
+	replies, errs := receiveAll(conn, 2)
+	if errs[0] != nil {
+		return 0, wrapError("GET", errs[0])
+	}
+	ttl, err = Int64(replies[1], errs[1])
+	if err != nil {
+		return 0, wrapError("TTL", err)
+	}
+	if replies[0] == nil {
+		return -2, ErrNil
+	}
+	return ttl, c.decode(replies[0], nil, val)
+}
+
+// GetObjects 使用 MGET 批量获取多个键的值，并逐个反序列化到 factory 为每个键返回的对象中（应该返回指针）。
+// 返回的map的键为传入的键名（不含前缀），不存在的键不会出现在结果中。
+// Example:
+//
+// ```golang
+// newUser := func(key string) interface{} { return &User{} }
+// users, err := c.GetObjects([]string{"user:1", "user:2"}, newUser)
+// ```
+func (c *Cacher) GetObjects(keys []string, factory func(key string) interface{}) (map[string]interface{}, error) {
+	if len(keys) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if key == "" {
+			return nil, ErrEmptyKey
+		}
+		args[i] = c.getKey(key)
+	}
+	values, err := redis.Values(c.Do("MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(keys))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		val := factory(keys[i])
+		if err := c.decode(value, nil, val); err != nil {
+			return nil, err
+		}
+		result[keys[i]] = val
+	}
+	return result, nil
+}
+
+// GetEx 获取键值并将有效时长重新设置为expire秒（GETEX ... EX），需要redis 6.2及以上版本。
+func (c *Cacher) GetEx(key string, expire int64) (interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if err := c.checkSupport("GETEX"); err != nil {
+		return nil, err
+	}
+	return c.Do("GETEX", c.getKey(key), "EX", expire)
+}
+
+// GetExString 获取string类型的键值并重新设置有效时长
+func (c *Cacher) GetExString(key string, expire int64) (string, error) {
+	return String(c.GetEx(key, expire))
+}
+
+// GetExInt 获取int类型的键值并重新设置有效时长
+func (c *Cacher) GetExInt(key string, expire int64) (int, error) {
+	return Int(c.GetEx(key, expire))
+}
+
+// GetExInt64 获取int64类型的键值并重新设置有效时长
+func (c *Cacher) GetExInt64(key string, expire int64) (int64, error) {
+	return Int64(c.GetEx(key, expire))
+}
+
+// GetExBool 获取bool类型的键值并重新设置有效时长
+func (c *Cacher) GetExBool(key string, expire int64) (bool, error) {
+	return Bool(c.GetEx(key, expire))
+}
+
+// GetExObject 获取非基本类型stuct的键值并重新设置有效时长
+func (c *Cacher) GetExObject(key string, expire int64, val interface{}) error {
+	reply, err := c.GetEx(key, expire)
+	return c.decode(reply, err, val)
+}
+
+// GetPersist 获取键值并移除键的有效时长（GETEX ... PERSIST），需要redis 6.2及以上版本。
+func (c *Cacher) GetPersist(key string) (interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if err := c.checkSupport("GETEX"); err != nil {
+		return nil, err
+	}
+	return c.Do("GETEX", c.getKey(key), "PERSIST")
+}
+
+// Set 存并设置有效时长。时长的单位为秒。
+// 基础类型和[]byte直接保存，其他用json.Marshal后转成string保存。设置了 TTLJitter 时，实际的有效期会在 expire 附近随机调整。
+func (c *Cacher) Set(key string, val interface{}, expire int64) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	value, err := c.encode(val)
+	if err != nil {
+		return err
+	}
+	if expire > 0 {
+		_, err := c.Do("SETEX", c.getKey(key), c.jitter(expire), value)
+		return err
+	}
+	_, err = c.Do("SET", c.getKey(key), value)
+	return err
+}
+
+// SetKeepTTL 与 Set 相同地保存值，但保留键原有的有效期（SET ... KEEPTTL），用于更新即将过期的缓存内容而不延长或清除它的有效期。
+// 键不存在或原来没有设置有效期时，保存后也没有有效期。需要redis 6.0及以上版本。
+func (c *Cacher) SetKeepTTL(key string, val interface{}) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if err := c.checkSupport("KEEPTTL"); err != nil {
+		return err
+	}
+	value, err := c.encode(val)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("SET", c.getKey(key), value, "KEEPTTL")
+	return err
+}
+
+// SetAndPublish 与 Set 相同地保存值，同时将序列化后的值发布到 channel 频道，订阅者可以直接使用新值更新自己的缓存。
+// SET（expire 大于0时为 SETEX）和 PUBLISH 在同一个连接上使用管道执行。频道名不会加上前缀。
+func (c *Cacher) SetAndPublish(key string, val interface{}, expire int64, channel string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	value, err := c.encode(val)
+	if err != nil {
+		return err
+	}
+	defer c.evictLocal("SET", c.getKey(key))
+	conn := c.getConn()
+	defer conn.Close()
+	commandName := "SET"
+	if expire > 0 {
+		commandName = "SETEX"
+		err = conn.Send(commandName, c.getKey(key), c.jitter(expire), value)
+	} else {
+		err = conn.Send(commandName, c.getKey(key), value)
+	}
+	if err != nil {
+		return wrapError(commandName, err)
+	}
+	if err := conn.Send("PUBLISH", channel, value); err != nil {
+		return wrapError("PUBLISH", err)
+	}
+	if err := conn.Flush(); err != nil {
+		return wrapError(commandName, err)
+	}
+	_, errs := receiveAll(conn, 2)
+	if errs[0] != nil {
+		return wrapError(commandName, errs[0])
+	}
+	return wrapError("PUBLISH", errs[1])
+}
+
+// heartbeatScript 值不同时才写入，值相同时只刷新有效期
+var heartbeatScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+redis.call("SET", KEYS[1], ARGV[1], "EX", ARGV[2])
+return 1`)
+
+// Heartbeat 用于存活状态等需要定期刷新有效期的键，ttl的单位为秒，必须大于0。
+// 使用Lua脚本原子地执行：当前值与val相同时只执行 EXPIRE 刷新有效期，不重写值；不同或键不存在时执行 SET ... EX 写入新值和有效期。
+func (c *Cacher) Heartbeat(key string, val interface{}, ttl int64) error {
+	if ttl <= 0 {
+		return fmt.Errorf("redisgo: Heartbeat: ttl must be positive, got %d", ttl)
+	}
+	value, err := c.encode(val)
+	if err != nil {
+		return err
+	}
+	_, err = c.Eval(heartbeatScript, []string{key}, value, ttl)
+	return err
+}
+
+// delIfEqualsScript 当前值与期望值相同时才删除键
+var delIfEqualsScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// DelIfEquals 当键的当前值与expected相同时才删除键，返回是否删除。expected 使用与 Set 相同的方式序列化后比较。
+// 使用Lua脚本原子地执行，用于删除自己读取过的缓存，避免误删其他进程在读取之后重新写入的值。
+func (c *Cacher) DelIfEquals(key string, expected interface{}) (bool, error) {
+	value, err := c.encode(expected)
+	if err != nil {
+		return false, err
+	}
+	return Bool(c.Eval(delIfEqualsScript, []string{key}, value))
+}
+
+// getDelScript 原子地读取并删除键，用于不支持 GETDEL 的低版本redis
+var getDelScript = redis.NewScript(1, `
+local value = redis.call("GET", KEYS[1])
+if value then
+	redis.call("DEL", KEYS[1])
+end
+return value`)
+
+// Consume 原子地读取并删除键，将值反序列化到 dest ，返回键是否存在。用于只能使用一次的令牌等场景，
+// 多个调用方同时消费同一个键时只有一个会返回 true ，避免先 Get 再 Del 时多个调用方都读到了值。
+// redis 6.2及以上版本使用 GETDEL ，低版本使用Lua脚本执行 GET 和 DEL 。
+func (c *Cacher) Consume(key string, dest interface{}) (bool, error) {
+	if key == "" {
+		return false, ErrEmptyKey
+	}
+	var reply interface{}
+	var err error
+	if c.Supports("GETDEL") {
+		reply, err = c.Do("GETDEL", c.getKey(key))
+	} else {
+		reply, err = c.Eval(getDelScript, []string{key})
+	}
+	if err == nil && reply == nil {
+		return false, nil
+	}
+	if err := c.decode(reply, err, dest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// defaultChunkSize GetStream 和 SetStream 默认每次读写的字节数
+const defaultChunkSize = 64 * 1024
+
+// GetStream 使用 GETRANGE 分块读取字符串键的值并写入 w ，读取很大的值时不需要把整个值加载到内存中。
+// 先使用 STRLEN 获取长度，再按 chunkSize 字节一块依次读取，chunkSize 小于等于0时默认为64KB。键不存在时返回 ErrNil 。
+// 各块是分别读取的，读取期间值被修改时可能得到新旧混合的数据。
+func (c *Cacher) GetStream(key string, w io.Writer, chunkSize int64) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	size, err := Int64(c.Do("STRLEN", c.getKey(key)))
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		// STRLEN 对不存在的键也返回0
+		if exists, err := c.Exists(key); err != nil || !exists {
+			if err == nil {
+				err = ErrNil
+			}
+			return err
+		}
+	}
+	for offset := int64(0); offset < size; offset += chunkSize {
+		chunk, err := redis.Bytes(c.Do("GETRANGE", c.getKey(key), offset, offset+chunkSize-1))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		// 值在读取期间变短了
+		if int64(len(chunk)) < chunkSize {
+			break
+		}
+	}
+	return nil
+}
+
+// SetStream 从 r 分块读取数据写入字符串键，写入很大的值时不需要先把整个值读到内存中。
+// 第一块使用 SET 写入，之后的块使用 APPEND 追加，全部写入后 expire 大于0时设置有效期，单位为秒。
+// 写入不是原子的：写入期间其他客户端可能读到不完整的值，r 返回错误时键中会留下已经写入的部分。
+// 需要原子地发布时，可以先写入临时键，再使用 RENAME 替换目标键。
+func (c *Cacher) SetStream(key string, r io.Reader, expire int64) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	buf := make([]byte, defaultChunkSize)
+	commandName := "SET"
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		// 第一块即使为空也要执行 SET ，以覆盖原来的值
+		if n > 0 || commandName == "SET" {
+			if _, err := c.Do(commandName, c.getKey(key), buf[:n]); err != nil {
+				return err
+			}
+			commandName = "APPEND"
+		}
+		if err != nil {
+			break
+		}
+	}
+	if expire > 0 {
+		return c.Expire(key, expire)
+	}
+	return nil
+}
+
+// appendRecordScript 在字符串末尾写入一条定长记录并返回记录的序号，字符串长度不是记录长度的整数倍时返回错误
+var appendRecordScript = redis.NewScript(1, `
+local size = tonumber(ARGV[2])
+local length = redis.call("STRLEN", KEYS[1])
+if length % size ~= 0 then
+	return redis.error_reply("ERR length " .. length .. " is not a multiple of record size " .. size)
+end
+redis.call("SETRANGE", KEYS[1], length, ARGV[1])
+return length / size`)
+
+// AppendRecord 将长度为 recordSize 字节的定长记录追加到字符串键中，返回记录的序号（从0开始），
+// 第 index 条记录位于偏移量 index*recordSize 处，可以使用 GETRANGE 随机读取，适合在一个键中紧凑地保存事件日志。
+// 使用Lua脚本原子地执行 STRLEN 和 SETRANGE ，多个客户端同时追加时不会写到同一个位置。
+// 同一个键的所有记录必须使用相同的 recordSize ，字符串长度不是 recordSize 的整数倍时返回错误。字符串键最大为512MB。
+func (c *Cacher) AppendRecord(key string, record []byte, recordSize int) (index int64, err error) {
+	if recordSize <= 0 || len(record) != recordSize {
+		return 0, fmt.Errorf("redisgo: AppendRecord: record is %d bytes, expected recordSize %d", len(record), recordSize)
+	}
+	return Int64(c.Eval(appendRecordScript, []string{key}, record, recordSize))
+}
+
+// Exists 检查键是否存在
+func (c *Cacher) Exists(key string) (bool, error) {
+	if key == "" {
+		return false, ErrEmptyKey
+	}
+	return Bool(c.Do("EXISTS", c.getKey(key)))
+}
+
+// ExistsFresh 使用 PTTL 检查键是否存在并且不会很快过期：PTTL 为-2（键不存在或已过期）时返回 false ；
+// 为-1（没有设置有效期）时返回 true ；否则剩余有效期大于 Options.ExistsFreshGrace 时才返回 true 。
+// 过期的键在被访问或被redis的定期清理删除之前仍然占用内存，但访问时redis会先检查有效期，所以 EXISTS 和 PTTL 都不会把已经过期的键当作存在；
+// 这里的宽限时长用于对即将过期的键给出确定的结果，避免检查之后、使用之前键恰好过期。
+func (c *Cacher) ExistsFresh(key string) (bool, error) {
+	if key == "" {
+		return false, ErrEmptyKey
+	}
+	ttl, err := Int64(c.Do("PTTL", c.getKey(key)))
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case ttl == -2:
+		return false, nil
+	case ttl == -1:
+		return true, nil
+	default:
+		return time.Duration(ttl)*time.Millisecond > c.existsFreshGrace, nil
+	}
+}
+
+// ExistsMap 使用管道对每个键执行 EXISTS ，返回每个键（不含前缀）是否存在，可以只为不存在的键重新计算缓存。
+func (c *Cacher) ExistsMap(keys ...string) (map[string]bool, error) {
+	for _, key := range keys {
+		if key == "" {
+			return nil, ErrEmptyKey
+		}
+	}
+	conn := c.getConn()
+	defer conn.Close()
+	for _, key := range keys {
+		if err := conn.Send("EXISTS", c.getKey(key)); err != nil {
+			return nil, wrapError("EXISTS", err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, wrapError("EXISTS", err)
+	}
+	replies, errs := receiveAll(conn, len(keys))
+	result := make(map[string]bool, len(keys))
+	var err error
+	for i, key := range keys {
+		exists, e := Bool(replies[i], errs[i])
+		if e != nil && err == nil {
+			err = wrapError("EXISTS", e)
+		}
+		result[key] = exists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sizeCommands 各数据类型返回元素数量（字符串为字节数）的命令
+var sizeCommands = map[string]string{
+	"string": "STRLEN",
+	"list":   "LLEN",
+	"set":    "SCARD",
+	"zset":   "ZCARD",
+	"hash":   "HLEN",
+	"stream": "XLEN",
+}
+
+// Size 返回键的元素数量，不需要事先知道键的类型：先执行 TYPE ，再根据类型执行 LLEN、SCARD、ZCARD、HLEN、XLEN ，
+// 字符串执行 STRLEN 返回字节数。需要两次网络往返。键不存在时返回0。
+func (c *Cacher) Size(key string) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	typ, err := String(c.Do("TYPE", c.getKey(key)))
+	if err != nil || typ == "none" {
+		return 0, err
+	}
+	commandName, ok := sizeCommands[typ]
+	if !ok {
+		return 0, fmt.Errorf("%w: unsupported key type %s", ErrUnexpectedReply, typ)
+	}
+	return Int64(c.Do(commandName, c.getKey(key)))
+}
+
+// Del 删除键
+func (c *Cacher) Del(key string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	_, err := c.Do("DEL", c.getKey(key))
+	return err
+}
+
+// Flush 清空当前数据库中的所有 key，慎用！
+func (c *Cacher) Flush() error {
+	_, err := c.Do("FLUSHDB")
+	return err
+}
+
+// errScanWithKeyRouter 设置了 KeyRouter 时调用 ScanPage 等遍历方法返回的错误
+var errScanWithKeyRouter = errors.New("redisgo: SCAN based methods do not support Options.KeyRouter, prefixes of routed keys are unknown")
+
+// ScanPage 执行一次 SCAN ，从 cursor 开始返回一页匹配 match 的键和下一次调用使用的游标，
+// 返回的游标为0时表示遍历结束。适用于需要把游标交给客户端、由客户端分页请求的无状态场景。
+// match 会加上前缀，为空时匹配前缀下的所有键，返回的键名会去掉前缀；count 小于等于0时使用redis的默认值。
+// 设置了 KeyRouter 时无法确定要遍历哪些前缀，返回错误，基于 ScanPage 的 FindBigKeys、FindKeysWithoutTTL 和 Export 同样如此。
+func (c *Cacher) ScanPage(cursor uint64, match string, count int) (keys []string, nextCursor uint64, err error) {
+	if c.keyRouter != nil {
+		return nil, 0, errScanWithKeyRouter
+	}
+	if match == "" {
+		match = "*"
+	}
+	args := redis.Args{}.Add(cursor, "MATCH", c.getKey(match))
+	if count > 0 {
+		args = args.Add("COUNT", count)
+	}
+	values, err := redis.Values(c.Do("SCAN", args...))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(values) != 2 {
+		return nil, 0, fmt.Errorf("%w: unexpected number of values, got %d", ErrUnexpectedReply, len(values))
+	}
+	nextCursor, err = redis.Uint64(values[0], nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	keys, err = redis.Strings(values[1], nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, key := range keys {
+		keys[i] = c.stripKey(key)
+	}
+	return keys, nextCursor, nil
+}
+
+// TTL 以秒为单位。当 key 不存在时，返回 -2 。 当 key 存在但没有设置剩余生存时间时，返回 -1
+func (c *Cacher) TTL(key string) (ttl int64, err error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	return Int64(c.Do("TTL", c.getKey(key)))
+}
+
+// Expire 设置键过期时间，expire的单位为秒
+func (c *Cacher) Expire(key string, expire int64) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	_, err := Bool(c.Do("EXPIRE", c.getKey(key), expire))
+	return err
+}
+
+// ExpireWithFlag 按条件设置键的过期时间，seconds 的单位为秒，返回有效期是否被修改（条件不满足或键不存在时为 false ）。需要redis 7.0及以上版本。
+// flag 可以是：NX 仅在键没有有效期时设置；XX 仅在键已有有效期时设置；GT 仅在新的有效期大于当前有效期时设置；LT 仅在小于时设置。
+// 没有有效期的键视为有效期无限长，所以 GT 不会为它设置有效期，LT 会。GT 可以用于只延长、不缩短会话的有效期。
+func (c *Cacher) ExpireWithFlag(key string, seconds int64, flag string) (bool, error) {
+	if key == "" {
+		return false, ErrEmptyKey
+	}
+	flag = strings.ToUpper(flag)
+	switch flag {
+	case "NX", "XX", "GT", "LT":
+	default:
+		return false, fmt.Errorf("redisgo: ExpireWithFlag: unknown flag %q", flag)
+	}
+	if err := c.checkSupport("EXPIRE " + flag); err != nil {
+		return false, err
+	}
+	ok, err := Bool(c.Do("EXPIRE", c.getKey(key), seconds, flag))
+	// 无法获取服务版本时 checkSupport 不会拦截，低版本的redis会以参数错误拒绝条件选项
+	var e redis.Error
+	if c.ServerVersion() == "" && errors.As(err, &e) && strings.HasPrefix(string(e), "ERR") {
+		return false, fmt.Errorf("%w: EXPIRE %s requires redis 7.0: %s", ErrUnsupported, flag, e)
+	}
+	return ok, err
+}
+
+// ExpireMany 使用管道为多个键设置相同的过期时间，expire的单位为秒。返回每个键是否存在（即是否设置成功）。
+func (c *Cacher) ExpireMany(expire int64, keys ...string) (map[string]bool, error) {
+	for _, key := range keys {
+		if key == "" {
+			return nil, ErrEmptyKey
+		}
+	}
+	// 过期时间不大于0时键会被删除
+	defer func() {
+		for _, key := range keys {
+			c.evictLocal("EXPIRE", c.getKey(key))
+		}
+	}()
+	conn := c.getConn()
+	defer conn.Close()
+	for _, key := range keys {
+		if err := conn.Send("EXPIRE", c.getKey(key), expire); err != nil {
+			return nil, wrapError("EXPIRE", err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, wrapError("EXPIRE", err)
+	}
+	replies, errs := receiveAll(conn, len(keys))
+	result := make(map[string]bool, len(keys))
+	var err error
+	for i, key := range keys {
+		ok, e := Bool(replies[i], errs[i])
+		if e != nil && err == nil {
+			err = wrapError("EXPIRE", e)
+		}
+		result[key] = ok
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ObjectFreq 返回键的访问频率（OBJECT FREQ），仅在 maxmemory-policy 为LFU策略（allkeys-lfu或volatile-lfu）时可用。
+func (c *Cacher) ObjectFreq(key string) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	freq, err := Int64(c.Do("OBJECT", "FREQ", c.getKey(key)))
+	var e redis.Error
+	if errors.As(err, &e) && strings.Contains(string(e), "LFU") {
+		return 0, fmt.Errorf("redisgo: OBJECT FREQ requires an LFU maxmemory-policy: %w", err)
+	}
+	return freq, err
+}
+
+// ObjectEncoding 返回键的内部编码（OBJECT ENCODING），比如 listpack、hashtable、intset 等。键不存在时返回 ErrNil 。
+func (c *Cacher) ObjectEncoding(key string) (string, error) {
+	if key == "" {
+		return "", ErrEmptyKey
+	}
+	return String(c.Do("OBJECT", "ENCODING", c.getKey(key)))
+}
+
+// AssertEncoding 检查键的内部编码是否为 expected ，不是时返回错误，主要用于测试。
+// 可以用来确认小的哈希、集合等仍然使用紧凑编码（比如 listpack），在数据结构意外转换为大集合的编码时发现性能退化。
+// 不同redis版本的编码名称不同，比如7.0以前的 ziplist 在之后的版本中为 listpack 。
+func (c *Cacher) AssertEncoding(key, expected string) error {
+	encoding, err := c.ObjectEncoding(key)
+	if err != nil {
+		return err
+	}
+	if encoding != expected {
+		return fmt.Errorf("redisgo: AssertEncoding: key %q has encoding %q, expected %q", key, encoding, expected)
+	}
+	return nil
+}
+
+// DebugObject 返回 DEBUG OBJECT 输出的键的调试信息，比如 encoding、serializedlength、ql_nodes 等。
+// 输出的 field:value 格式的内容会解析为map。云服务商提供的redis通常会禁用 DEBUG 命令，此时返回redis的错误。
+func (c *Cacher) DebugObject(key string) (map[string]string, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	status, err := String(c.Do("DEBUG", "OBJECT", c.getKey(key)))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for _, field := range strings.Fields(status) {
+		if i := strings.Index(field, ":"); i > 0 {
+			result[field[:i]] = field[i+1:]
 		}
-		if opts.Addr == "" {
-			opts.Addr = "127.0.0.1:6379"
+	}
+	return result, nil
+}
+
+// KeyInfo FindBigKeys 返回的键信息
+type KeyInfo struct {
+	Key  string // 去掉前缀后的键名
+	Type string // TYPE 返回的类型，比如 string、hash、list
+	Size int64  // MEMORY USAGE 返回的占用内存字节数
+}
+
+// FindBigKeys 使用 SCAN 遍历匹配 match 的键，对其中最多 sampleLimit 个键查询 MEMORY USAGE 和 TYPE ，
+// 按占用内存从大到小返回，相当于限定在前缀下的 redis-cli --bigkeys 。
+// match 的用法与 ScanPage 相同；count 为每次 SCAN 的 COUNT 参数；sampleLimit 用于限制对生产环境的压力，小于等于0时不限制。
+func (c *Cacher) FindBigKeys(match string, count int, sampleLimit int) ([]KeyInfo, error) {
+	var result []KeyInfo
+	var cursor uint64
+	for {
+		keys, next, err := c.ScanPage(cursor, match, count)
+		if err != nil {
+			return nil, err
+		}
+		if sampleLimit > 0 && len(result)+len(keys) > sampleLimit {
+			keys = keys[:sampleLimit-len(result)]
+		}
+		infos, err := c.keyInfos(keys)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, infos...)
+		cursor = next
+		if cursor == 0 || (sampleLimit > 0 && len(result) >= sampleLimit) {
+			break
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Size > result[j].Size
+	})
+	return result, nil
+}
+
+// keyInfos 使用管道查询键的 TYPE 和 MEMORY USAGE ，忽略查询期间已经不存在的键
+func (c *Cacher) keyInfos(keys []string) ([]KeyInfo, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	conn := c.getConn()
+	defer conn.Close()
+	for _, key := range keys {
+		if err := conn.Send("TYPE", c.getKey(key)); err != nil {
+			return nil, wrapError("TYPE", err)
 		}
-		if opts.MaxIdle == 0 {
-			opts.MaxIdle = 3
+		if err := conn.Send("MEMORY", "USAGE", c.getKey(key)); err != nil {
+			return nil, wrapError("MEMORY", err)
 		}
-		if opts.IdleTimeout == 0 {
-			opts.IdleTimeout = 300
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, wrapError("MEMORY", err)
+	}
+	replies, errs := receiveAll(conn, 2*len(keys))
+	infos := make([]KeyInfo, 0, len(keys))
+	var err error
+	for i, key := range keys {
+		typ, e1 := String(replies[2*i], errs[2*i])
+		size, e2 := Int64(replies[2*i+1], errs[2*i+1])
+		if e1 != nil && err == nil {
+			err = wrapError("TYPE", e1)
 		}
-		if opts.Marshal == nil {
-			c.marshal = json.Marshal
+		if e2 != nil && e2 != ErrNil && err == nil {
+			err = wrapError("MEMORY", e2)
 		}
-		if opts.Unmarshal == nil {
-			c.unmarshal = json.Unmarshal
+		if e2 == ErrNil || typ == "none" {
+			continue
 		}
-		pool := &redis.Pool{
-			MaxActive:   opts.MaxActive,
-			MaxIdle:     opts.MaxIdle,
-			IdleTimeout: time.Duration(opts.IdleTimeout) * time.Second,
+		infos = append(infos, KeyInfo{Key: key, Type: typ, Size: size})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
 
-			Dial: func() (redis.Conn, error) {
-				conn, err := redis.Dial(opts.Network, opts.Addr)
-				if err != nil {
-					return nil, err
-				}
-				if opts.Password != "" {
-					if _, err := conn.Do("AUTH", opts.Password); err != nil {
-						conn.Close()
-						return nil, err
-					}
-				}
-				if _, err := conn.Do("SELECT", opts.Db); err != nil {
-					conn.Close()
-					return nil, err
-				}
-				return conn, err
-			},
+// maxKeysWithoutTTL FindKeysWithoutTTL 最多返回的键数量
+const maxKeysWithoutTTL = 10000
 
-			TestOnBorrow: func(conn redis.Conn, t time.Time) error {
-				_, err := conn.Do("PING")
-				return err
-			},
+// FindKeysWithoutTTL 使用 SCAN 遍历匹配 match 的键，返回其中没有设置有效期（TTL 为-1）的键，用于发现忘记设置有效期的缓存键。
+// match 的用法与 ScanPage 相同，返回的键名会去掉前缀；count 为每次 SCAN 的 COUNT 参数。每页的 TTL 使用管道查询，
+// 但仍然需要遍历前缀下的所有键，键很多时耗时较长，应该在业务低峰期执行或使用 match 缩小范围。找到 10000 个键后停止遍历。
+func (c *Cacher) FindKeysWithoutTTL(match string, count int) ([]string, error) {
+	var result []string
+	var cursor uint64
+	for {
+		keys, next, err := c.ScanPage(cursor, match, count)
+		if err != nil {
+			return nil, err
+		}
+		persistent, err := c.persistentKeys(keys)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, persistent...)
+		cursor = next
+		if cursor == 0 || len(result) >= maxKeysWithoutTTL {
+			break
 		}
-
-		c.pool = pool
-		c.closePool()
-		return nil
-	default:
-		return errors.New("Unsupported options")
 	}
+	if len(result) > maxKeysWithoutTTL {
+		result = result[:maxKeysWithoutTTL]
+	}
+	return result, nil
 }
 
-// Do 执行redis命令并返回结果。执行时从连接池获取连接并在执行完命令后关闭连接。
-func (c *Cacher) Do(commandName string, args ...interface{}) (reply interface{}, err error) {
-	conn := c.pool.Get()
+// persistentKeys 使用管道查询键的 TTL ，返回没有设置有效期的键
+func (c *Cacher) persistentKeys(keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	conn := c.getConn()
 	defer conn.Close()
-	return conn.Do(commandName, args...)
-}
-
-// Get 获取键值。一般不直接使用该值，而是配合下面的工具类方法获取具体类型的值，或者直接使用github.com/gomodule/redigo/redis包的工具方法。
-func (c *Cacher) Get(key string) (interface{}, error) {
-	return c.Do("GET", c.getKey(key))
+	for _, key := range keys {
+		if err := conn.Send("TTL", c.getKey(key)); err != nil {
+			return nil, wrapError("TTL", err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, wrapError("TTL", err)
+	}
+	replies, errs := receiveAll(conn, len(keys))
+	var persistent []string
+	var err error
+	for i, key := range keys {
+		ttl, e := Int64(replies[i], errs[i])
+		if e != nil && err == nil {
+			err = wrapError("TTL", e)
+		}
+		if e == nil && ttl == -1 {
+			persistent = append(persistent, key)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return persistent, nil
 }
 
-// GetString 获取string类型的键值
-func (c *Cacher) GetString(key string) (string, error) {
-	return String(c.Get(key))
+// ConfigGet 返回 CONFIG GET 获取的服务配置，parameter 可以使用通配符（比如 maxmemory*），此时返回所有匹配的配置项。
+// 云服务商提供的redis通常会禁用或重命名 CONFIG 命令，此时返回的错误会说明这一点。
+func (c *Cacher) ConfigGet(parameter string) (map[string]string, error) {
+	config, err := redis.StringMap(c.Do("CONFIG", "GET", parameter))
+	return config, configError(err)
 }
 
-// GetInt 获取int类型的键值
-func (c *Cacher) GetInt(key string) (int, error) {
-	return Int(c.Get(key))
+// ConfigSet 使用 CONFIG SET 在运行时修改服务配置，修改不会写入配置文件，重启后失效。
+// CONFIG 命令被禁用时返回的错误与 ConfigGet 相同。
+func (c *Cacher) ConfigSet(parameter, value string) error {
+	_, err := c.Do("CONFIG", "SET", parameter, value)
+	return configError(err)
 }
 
-// GetInt64 获取int64类型的键值
-func (c *Cacher) GetInt64(key string) (int64, error) {
-	return Int64(c.Get(key))
+// configError 为 CONFIG 命令不可用的错误加上说明
+func configError(err error) error {
+	var e redis.Error
+	if errors.As(err, &e) && strings.Contains(strings.ToLower(string(e)), "unknown command") {
+		return fmt.Errorf("redisgo: CONFIG is disabled or renamed on this server: %w", err)
+	}
+	return err
 }
 
-// GetBool 获取bool类型的键值
-func (c *Cacher) GetBool(key string) (bool, error) {
-	return Bool(c.Get(key))
+// ServerTime 使用 TIME 命令返回redis服务的当前时间，精度为微秒。多个节点的本地时钟可能有偏差，
+// 需要统一的时间戳或者按服务端时钟计算有效期时可以使用。返回的时间包含一次网络往返的延迟。
+func (c *Cacher) ServerTime() (time.Time, error) {
+	values, err := redis.Int64s(c.Do("TIME"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(values) != 2 {
+		return time.Time{}, fmt.Errorf("%w: TIME expects 2 values, got %d", ErrUnexpectedReply, len(values))
+	}
+	return time.Unix(values[0], values[1]*int64(time.Microsecond)), nil
 }
 
-// GetObject 获取非基本类型stuct的键值。在实现上，使用json的Marshal和Unmarshal做序列化存取。
-func (c *Cacher) GetObject(key string, val interface{}) error {
-	reply, err := c.Get(key)
-	return c.decode(reply, err, val)
+// SlowLogEntry 慢查询日志中的一条记录
+type SlowLogEntry struct {
+	ID         int64         // 日志的唯一编号
+	Time       time.Time     // 命令开始执行的时间
+	Duration   time.Duration // 命令的执行耗时，精度为微秒
+	Args       []string      // 命令及其参数，参数过多或过长时redis会截断
+	ClientAddr string        // 客户端地址，redis 4.0及以上版本才有
+	ClientName string        // 客户端名称（CLIENT SETNAME），redis 4.0及以上版本才有
 }
 
-// Set 存并设置有效时长。时长的单位为秒。
-// 基础类型直接保存，其他用json.Marshal后转成string保存。
-func (c *Cacher) Set(key string, val interface{}, expire int64) error {
-	value, err := c.encode(val)
+// SlowLogGet 使用 SLOWLOG GET 返回最近的 count 条慢查询日志，最新的在前。count 小于0时返回全部日志。
+func (c *Cacher) SlowLogGet(count int) ([]SlowLogEntry, error) {
+	values, err := redis.Values(c.Do("SLOWLOG", "GET", count))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if expire > 0 {
-		_, err := c.Do("SETEX", c.getKey(key), expire, value)
-		return err
+	entries := make([]SlowLogEntry, 0, len(values))
+	for _, value := range values {
+		entry, err := parseSlowLogEntry(value)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
 	}
-	_, err = c.Do("SET", c.getKey(key), value)
-	return err
+	return entries, nil
 }
 
-// Exists 检查键是否存在
-func (c *Cacher) Exists(key string) (bool, error) {
-	return Bool(c.Do("EXISTS", c.getKey(key)))
+// SlowLogReset 使用 SLOWLOG RESET 清空慢查询日志
+func (c *Cacher) SlowLogReset() error {
+	_, err := c.Do("SLOWLOG", "RESET")
+	return err
 }
 
-//Del 删除键
-func (c *Cacher) Del(key string) error {
-	_, err := c.Do("DEL", c.getKey(key))
-	return err
+// parseSlowLogEntry 解析 SLOWLOG GET 返回的一条日志：编号、时间戳、耗时（微秒）、命令参数数组，以及4.0开始增加的客户端地址和名称
+func parseSlowLogEntry(reply interface{}) (SlowLogEntry, error) {
+	var entry SlowLogEntry
+	fields, err := redis.Values(reply, nil)
+	if err != nil {
+		return entry, err
+	}
+	if len(fields) < 4 {
+		return entry, fmt.Errorf("%w: unexpected number of slowlog fields, got %d", ErrUnexpectedReply, len(fields))
+	}
+	if entry.ID, err = redis.Int64(fields[0], nil); err != nil {
+		return entry, err
+	}
+	timestamp, err := redis.Int64(fields[1], nil)
+	if err != nil {
+		return entry, err
+	}
+	entry.Time = time.Unix(timestamp, 0)
+	micros, err := redis.Int64(fields[2], nil)
+	if err != nil {
+		return entry, err
+	}
+	entry.Duration = time.Duration(micros) * time.Microsecond
+	if entry.Args, err = redis.Strings(fields[3], nil); err != nil {
+		return entry, err
+	}
+	if len(fields) >= 6 {
+		entry.ClientAddr, _ = redis.String(fields[4], nil)
+		entry.ClientName, _ = redis.String(fields[5], nil)
+	}
+	return entry, nil
+}
+
+// ClientInfo CLIENT LIST 返回的一个客户端连接的信息
+type ClientInfo struct {
+	ID     int64             // 连接的唯一编号
+	Addr   string            // 客户端地址，可以传给 ClientKill
+	Name   string            // 连接名称（CLIENT SETNAME）
+	Age    int64             // 连接建立的秒数
+	Idle   int64             // 连接空闲的秒数
+	DB     int               // 当前使用的数据库
+	Flags  string            // 连接的标记，比如 N 为普通连接，P 为订阅连接
+	Cmd    string            // 最后执行的命令
+	Fields map[string]string // 所有字段的原始值，包含上面没有列出的字段
+}
+
+// ClientList 使用 CLIENT LIST 返回连接到redis服务的所有客户端连接，可以用于排查连接泄漏（包括 Subscribe 建立的订阅连接）。
+// 云服务商提供的redis可能会限制 CLIENT 命令，此时返回redis的错误。
+func (c *Cacher) ClientList() ([]ClientInfo, error) {
+	list, err := String(c.Do("CLIENT", "LIST"))
+	if err != nil {
+		return nil, err
+	}
+	var clients []ClientInfo
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		clients = append(clients, parseClientInfo(line))
+	}
+	return clients, nil
 }
 
-// Flush 清空当前数据库中的所有 key，慎用！
-func (c *Cacher) Flush() error {
-	_, err := c.Do("FLUSHDB")
+// ClientKill 使用 CLIENT KILL ADDR 关闭指定地址（ip:port）的客户端连接。与 ClientList 一样，CLIENT 命令可能被限制。
+func (c *Cacher) ClientKill(addr string) error {
+	_, err := c.Do("CLIENT", "KILL", "ADDR", addr)
 	return err
 }
 
-// TTL 以秒为单位。当 key 不存在时，返回 -2 。 当 key 存在但没有设置剩余生存时间时，返回 -1
-func (c *Cacher) TTL(key string) (ttl int64, err error) {
-	return Int64(c.Do("TTL", c.getKey(key)))
+// parseClientInfo 解析 CLIENT LIST 返回的一行 key=value 格式的连接信息
+func parseClientInfo(line string) ClientInfo {
+	info := ClientInfo{Fields: make(map[string]string)}
+	for _, field := range strings.Fields(line) {
+		if i := strings.Index(field, "="); i > 0 {
+			info.Fields[field[:i]] = field[i+1:]
+		}
+	}
+	info.ID, _ = strconv.ParseInt(info.Fields["id"], 10, 64)
+	info.Addr = info.Fields["addr"]
+	info.Name = info.Fields["name"]
+	info.Age, _ = strconv.ParseInt(info.Fields["age"], 10, 64)
+	info.Idle, _ = strconv.ParseInt(info.Fields["idle"], 10, 64)
+	info.DB, _ = strconv.Atoi(info.Fields["db"])
+	info.Flags = info.Fields["flags"]
+	info.Cmd = info.Fields["cmd"]
+	return info
+}
+
+// LCS 返回两个字符串键的最长公共子序列，需要redis 7.0及以上版本，低版本返回 ErrUnsupported 。键不存在时当作空字符串。
+func (c *Cacher) LCS(key1, key2 string) (string, error) {
+	if key1 == "" || key2 == "" {
+		return "", ErrEmptyKey
+	}
+	if err := c.checkSupport("LCS"); err != nil {
+		return "", err
+	}
+	return String(c.Do("LCS", c.getKey(key1), c.getKey(key2)))
 }
 
-// Expire 设置键过期时间，expire的单位为秒
-func (c *Cacher) Expire(key string, expire int64) error {
-	_, err := Bool(c.Do("EXPIRE", c.getKey(key), expire))
-	return err
+// LCSLen 返回两个字符串键的最长公共子序列的长度，需要redis 7.0及以上版本，低版本返回 ErrUnsupported 。
+func (c *Cacher) LCSLen(key1, key2 string) (int64, error) {
+	if key1 == "" || key2 == "" {
+		return 0, ErrEmptyKey
+	}
+	if err := c.checkSupport("LCS"); err != nil {
+		return 0, err
+	}
+	return Int64(c.Do("LCS", c.getKey(key1), c.getKey(key2), "LEN"))
 }
 
 // Incr 将 key 中储存的数字值增一
 func (c *Cacher) Incr(key string) (val int64, err error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	return Int64(c.Do("INCR", c.getKey(key)))
 }
 
 // IncrBy 将 key 所储存的值加上给定的增量值（increment）。
 func (c *Cacher) IncrBy(key string, amount int64) (val int64, err error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	return Int64(c.Do("INCRBY", c.getKey(key), amount))
 }
 
 // Decr 将 key 中储存的数字值减一。
 func (c *Cacher) Decr(key string) (val int64, err error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	return Int64(c.Do("DECR", c.getKey(key)))
 }
 
 // DecrBy key 所储存的值减去给定的减量值（decrement）。
 func (c *Cacher) DecrBy(key string, amount int64) (val int64, err error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	return Int64(c.Do("DECRBY", c.getKey(key), amount))
 }
 
+// safeIncrScript 去重标记不存在时才增加计数，标记存在时返回当前的值
+var safeIncrScript = redis.NewScript(2, `
+if redis.call("SET", KEYS[2], 1, "NX", "PX", ARGV[1]) then
+	return redis.call("INCR", KEYS[1])
+end
+return tonumber(redis.call("GET", KEYS[1]) or "0")`)
+
+// SafeIncr 幂等的 INCR ：同一个 dedupeID 在 ttl 时间内只会增加一次，重复调用返回当前的值。
+// 用于超时等命令可能已经执行成功的情况下安全地重试，重试时使用相同的 dedupeID 。
+// 去重标记保存在键名后加上 ":dedupe:" 和 dedupeID 的键中，有效期为 ttl （精度为毫秒）。ttl 越长可以去重的重试时间越长，
+// 但是每个 dedupeID 都会占用一个键直到过期；超过 ttl 后使用相同的 dedupeID 会再次增加。使用Lua脚本原子地执行。
+func (c *Cacher) SafeIncr(key, dedupeID string, ttl time.Duration) (int64, error) {
+	if dedupeID == "" {
+		return 0, fmt.Errorf("redisgo: SafeIncr: empty dedupeID")
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("redisgo: SafeIncr: ttl must be positive, got %s", ttl)
+	}
+	return Int64(c.Eval(safeIncrScript, []string{key, key + ":dedupe:" + dedupeID}, ttl.Milliseconds()))
+}
+
+// incrWithExpireScript 增加计数，键是这次新建的（结果等于增量）时设置有效期
+var incrWithExpireScript = redis.NewScript(1, `
+local count = redis.call("INCRBY", KEYS[1], ARGV[1])
+if count == tonumber(ARGV[1]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return count`)
+
+// IncrWithExpire 将键的值加上 amount 并返回新的值，如果是窗口内的第一次增加（键是这次新建的），同时将有效期设置为 window ，适用于按时间窗口统计配额。
+// 使用Lua脚本原子地执行，避免 INCR 成功后进程退出、没有执行 EXPIRE 导致键永不过期。window 的精度为毫秒，amount 应该大于0。
+func (c *Cacher) IncrWithExpire(key string, amount int64, window time.Duration) (int64, error) {
+	if window <= 0 {
+		return 0, fmt.Errorf("redisgo: IncrWithExpire: window must be positive, got %s", window)
+	}
+	return Int64(c.Eval(incrWithExpireScript, []string{key}, amount, window.Milliseconds()))
+}
+
+// rotateCounterScript 将计数器重置为0，并把原来的值记录到历史哈希表中
+var rotateCounterScript = redis.NewScript(2, `
+local old = tonumber(redis.call("GETSET", KEYS[1], 0) or "0")
+redis.call("HSET", KEYS[2], ARGV[1], old)
+return old`)
+
+// RotateCounter 原子地读取计数器 counterKey 的值并重置为0，同时将原来的值保存到哈希表 historyKey 的 periodField 字段，返回原来的值。
+// 用于按周期统计：周期内使用 Incr 等方法增加计数，周期结束时调用本方法归档，避免读取和重置之间的计数丢失。
+// 计数器不存在时按0归档。重置使用 GETSET ，会清除计数器原有的有效期。使用Lua脚本原子地执行。
+func (c *Cacher) RotateCounter(counterKey, historyKey, periodField string) (int64, error) {
+	return Int64(c.Eval(rotateCounterScript, []string{counterKey, historyKey}, periodField))
+}
+
 // HMSet 将一个map存到Redis hash，同时设置有效期，单位：秒
+// val 可以是map或结构体，使用redigo的 redis.Args.AddFlat 展开为字段和值，不使用配置的序列化方法（Marshal）：
+// 字符串、数字和[]byte原样保存，其他类型的值（比如 time.Time 、嵌套的结构体）按 fmt.Sprint 的格式保存。
+// 这种格式与 HGetAll 使用的 redis.ScanStruct 对应，适合字段都是基础类型的扁平结构体；
+// 需要按序列化方法保存字段值时，使用 HSet 逐个字段保存，并使用 HGet 或 HGetAllObjects 读取。
 // Example:
 //
 // ```golang
@@ -216,23 +1800,79 @@ func (c *Cacher) DecrBy(key string, amount int64) (val int64, err error) {
 // err := c.HMSet("user", m, 10)
 // ```
 func (c *Cacher) HMSet(key string, val interface{}, expire int) (err error) {
-	conn := c.pool.Get()
+	if key == "" {
+		return ErrEmptyKey
+	}
+	conn := c.getConn()
 	defer conn.Close()
 	err = conn.Send("HMSET", redis.Args{}.Add(c.getKey(key)).AddFlat(val)...)
 	if err != nil {
 		return
 	}
+	count := 1
 	if expire > 0 {
 		err = conn.Send("EXPIRE", c.getKey(key), int64(expire))
+		count++
 	}
 	if err != nil {
 		return
 	}
-	conn.Flush()
-	_, err = conn.Receive()
+	if err = conn.Flush(); err != nil {
+		return wrapError("HMSET", err)
+	}
+	_, errs := receiveAll(conn, count)
+	if errs[0] != nil {
+		return wrapError("HMSET", errs[0])
+	}
+	if count > 1 {
+		err = wrapError("EXPIRE", errs[1])
+	}
 	return
 }
 
+// HMSetMany 使用管道批量执行HMSet，items的键为哈希表的键名，值的格式与HMSet相同（同样不使用配置的序列化方法）。expire大于0时同时设置有效期，单位：秒
+// Example:
+//
+// ```golang
+// items := make(map[string]interface{})
+// items["product:1"] = map[string]interface{}{"name": "apple", "price": 5}
+// items["product:2"] = map[string]interface{}{"name": "pear", "price": 3}
+// err := c.HMSetMany(items, 3600)
+// ```
+func (c *Cacher) HMSetMany(items map[string]interface{}, expire int64) error {
+	for key := range items {
+		if key == "" {
+			return ErrEmptyKey
+		}
+	}
+	conn := c.getConn()
+	defer conn.Close()
+	count := 0
+	for key, val := range items {
+		if err := conn.Send("HMSET", redis.Args{}.Add(c.getKey(key)).AddFlat(val)...); err != nil {
+			return wrapError("HMSET", err)
+		}
+		count++
+		if expire > 0 {
+			if err := conn.Send("EXPIRE", c.getKey(key), expire); err != nil {
+				return wrapError("EXPIRE", err)
+			}
+			count++
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return wrapError("HMSET", err)
+	}
+	_, errs := receiveAll(conn, count)
+	var err error
+	for _, e := range errs {
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	return wrapError("HMSET", err)
+}
+
 /** Redis hash 是一个string类型的field和value的映射表，hash特别适合用于存储对象。 **/
 
 // HSet 将哈希表 key 中的字段 field 的值设为 val
@@ -242,6 +1882,9 @@ func (c *Cacher) HMSet(key string, val interface{}, expire int) (err error) {
 // _, err := c.HSet("user", "age", 23)
 // ```
 func (c *Cacher) HSet(key, field string, val interface{}) (interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	value, err := c.encode(val)
 	if err != nil {
 		return nil, err
@@ -256,6 +1899,9 @@ func (c *Cacher) HSet(key, field string, val interface{}) (interface{}, error) {
 // val, err := c.HGet("user", "age")
 // ```
 func (c *Cacher) HGet(key, field string) (reply interface{}, err error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	reply, err = c.Do("HGET", c.getKey(key), field)
 	return
 }
@@ -292,6 +1938,9 @@ func (c *Cacher) HGetObject(key, field string, val interface{}) error {
 
 // HGetAll HGetAll("key", &val)
 func (c *Cacher) HGetAll(key string, val interface{}) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
 	v, err := redis.Values(c.Do("HGETALL", c.getKey(key)))
 	if err != nil {
 		return err
@@ -304,6 +1953,103 @@ func (c *Cacher) HGetAll(key string, val interface{}) error {
 	return err
 }
 
+// HGetAllObjects 使用 HGETALL 读取哈希表的所有字段，并将每个字段的值反序列化到 factory 为该字段返回的对象中（应该返回指针），
+// 适用于每个字段保存一个序列化对象的哈希表（比如字段为用户ID，值为用户对象）。返回的map的键为字段名，键不存在时返回空map。
+// Example:
+//
+// ```golang
+// newUser := func(field string) interface{} { return &User{} }
+// users, err := c.HGetAllObjects("users", newUser)
+// ```
+func (c *Cacher) HGetAllObjects(key string, factory func(field string) interface{}) (map[string]interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	values, err := redis.Values(c.Do("HGETALL", c.getKey(key)))
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, fmt.Errorf("%w: odd number of values, got %d", ErrUnexpectedReply, len(values))
+	}
+	result := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		field, err := redis.String(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		val := factory(field)
+		if err := c.decode(values[i+1], nil, val); err != nil {
+			return nil, err
+		}
+		result[field] = val
+	}
+	return result, nil
+}
+
+// HRandField 从哈希表中随机返回 count 个字段名，需要redis 6.2及以上版本。
+// count 为正数时返回不重复的字段，数量不超过哈希表的字段数；count 为负数时返回 count 的绝对值个字段，字段可能重复。
+func (c *Cacher) HRandField(key string, count int) ([]string, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if err := c.checkSupport("HRANDFIELD"); err != nil {
+		return nil, err
+	}
+	return redis.Strings(c.Do("HRANDFIELD", c.getKey(key), count))
+}
+
+// HRandFieldWithValues 从哈希表中随机返回 count 个字段及其值，count 的用法与 HRandField 相同。
+// 由于返回的是map，count 为负数时重复的字段只会保留一个。
+func (c *Cacher) HRandFieldWithValues(key string, count int) (map[string]string, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if err := c.checkSupport("HRANDFIELD"); err != nil {
+		return nil, err
+	}
+	return redis.StringMap(c.Do("HRANDFIELD", c.getKey(key), count, "WITHVALUES"))
+}
+
+// HExpire 为哈希表的字段设置过期时间，ttl 的单位为秒，需要redis 7.4及以上版本，低版本返回 ErrUnsupported 。
+// 按字段顺序返回每个字段的结果：-2 字段不存在，0 条件不满足，1 设置成功，2 ttl 为0立即删除了字段。
+func (c *Cacher) HExpire(key string, ttl int64, fields ...string) ([]int64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if err := c.checkSupport("HEXPIRE"); err != nil {
+		return nil, err
+	}
+	args := redis.Args{}.Add(c.getKey(key), ttl, "FIELDS", len(fields)).AddFlat(fields)
+	return redis.Int64s(c.Do("HEXPIRE", args...))
+}
+
+// HTTL 返回哈希表字段的剩余过期时间，单位为秒，需要redis 7.4及以上版本，低版本返回 ErrUnsupported 。
+// 按字段顺序返回每个字段的结果：-2 字段不存在，-1 字段没有设置过期时间。
+func (c *Cacher) HTTL(key string, fields ...string) ([]int64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if err := c.checkSupport("HTTL"); err != nil {
+		return nil, err
+	}
+	args := redis.Args{}.Add(c.getKey(key), "FIELDS", len(fields)).AddFlat(fields)
+	return redis.Int64s(c.Do("HTTL", args...))
+}
+
+// HPersist 移除哈希表字段的过期时间，需要redis 7.4及以上版本，低版本返回 ErrUnsupported 。
+// 按字段顺序返回每个字段的结果：-2 字段不存在，-1 字段没有设置过期时间，1 移除成功。
+func (c *Cacher) HPersist(key string, fields ...string) ([]int64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if err := c.checkSupport("HPERSIST"); err != nil {
+		return nil, err
+	}
+	args := redis.Args{}.Add(c.getKey(key), "FIELDS", len(fields)).AddFlat(fields)
+	return redis.Int64s(c.Do("HPERSIST", args...))
+}
+
 /**
 Redis列表是简单的字符串列表，按照插入顺序排序。你可以添加一个元素到列表的头部（左边）或者尾部（右边）
 **/
@@ -311,12 +2057,15 @@ Redis列表是简单的字符串列表，按照插入顺序排序。你可以添
 // BLPop 它是 LPOP 命令的阻塞版本，当给定列表内没有任何元素可供弹出的时候，连接将被 BLPOP 命令阻塞，直到等待超时或发现可弹出元素为止。
 // 超时参数 timeout 接受一个以秒为单位的数字作为值。超时参数设为 0 表示阻塞时间可以无限期延长(block indefinitely) 。
 func (c *Cacher) BLPop(key string, timeout int) (interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	values, err := redis.Values(c.Do("BLPOP", c.getKey(key), timeout))
 	if err != nil {
 		return nil, err
 	}
 	if len(values) != 2 {
-		return nil, fmt.Errorf("redisgo: unexpected number of values, got %d", len(values))
+		return nil, fmt.Errorf("%w: unexpected number of values, got %d", ErrUnexpectedReply, len(values))
 	}
 	return values[1], err
 }
@@ -350,12 +2099,15 @@ func (c *Cacher) BLPopObject(key string, timeout int, val interface{}) error {
 // BRPop 它是 RPOP 命令的阻塞版本，当给定列表内没有任何元素可供弹出的时候，连接将被 BRPOP 命令阻塞，直到等待超时或发现可弹出元素为止。
 // 超时参数 timeout 接受一个以秒为单位的数字作为值。超时参数设为 0 表示阻塞时间可以无限期延长(block indefinitely) 。
 func (c *Cacher) BRPop(key string, timeout int) (interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	values, err := redis.Values(c.Do("BRPOP", c.getKey(key), timeout))
 	if err != nil {
 		return nil, err
 	}
 	if len(values) != 2 {
-		return nil, fmt.Errorf("redisgo: unexpected number of values, got %d", len(values))
+		return nil, fmt.Errorf("%w: unexpected number of values, got %d", ErrUnexpectedReply, len(values))
 	}
 	return values[1], err
 }
@@ -388,6 +2140,9 @@ func (c *Cacher) BRPopObject(key string, timeout int, val interface{}) error {
 
 // LPop 移出并获取列表中的第一个元素（表头，左边）
 func (c *Cacher) LPop(key string) (interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	return c.Do("LPOP", c.getKey(key))
 }
 
@@ -419,6 +2174,9 @@ func (c *Cacher) LPopObject(key string, val interface{}) error {
 
 // RPop 移出并获取列表中的最后一个元素（表尾，右边）
 func (c *Cacher) RPop(key string) (interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	return c.Do("RPOP", c.getKey(key))
 }
 
@@ -450,6 +2208,9 @@ func (c *Cacher) RPopObject(key string, val interface{}) error {
 
 // LPush 将一个值插入到列表头部
 func (c *Cacher) LPush(key string, member interface{}) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
 	value, err := c.encode(member)
 	if err != nil {
 		return err
@@ -460,6 +2221,9 @@ func (c *Cacher) LPush(key string, member interface{}) error {
 
 // RPush 将一个值插入到列表尾部
 func (c *Cacher) RPush(key string, member interface{}) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
 	value, err := c.encode(member)
 	if err != nil {
 		return err
@@ -468,6 +2232,37 @@ func (c *Cacher) RPush(key string, member interface{}) error {
 	return err
 }
 
+// PushCapped 将一个值插入到列表尾部，并只保留最后的 maxLen 个元素，适用于保存最近的事件等固定长度的列表。
+// RPUSH 和 LTRIM 在同一个连接上使用管道执行，maxLen 必须大于0。
+func (c *Cacher) PushCapped(key string, val interface{}, maxLen int64) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if maxLen <= 0 {
+		return fmt.Errorf("redisgo: PushCapped: maxLen must be positive, got %d", maxLen)
+	}
+	value, err := c.encode(val)
+	if err != nil {
+		return err
+	}
+	conn := c.getConn()
+	defer conn.Close()
+	if err := conn.Send("RPUSH", c.getKey(key), value); err != nil {
+		return wrapError("RPUSH", err)
+	}
+	if err := conn.Send("LTRIM", c.getKey(key), -maxLen, -1); err != nil {
+		return wrapError("LTRIM", err)
+	}
+	if err := conn.Flush(); err != nil {
+		return wrapError("RPUSH", err)
+	}
+	_, errs := receiveAll(conn, 2)
+	if errs[0] != nil {
+		return wrapError("RPUSH", errs[0])
+	}
+	return wrapError("LTRIM", errs[1])
+}
+
 // LREM 根据参数 count 的值，移除列表中与参数 member 相等的元素。
 // count 的值可以是以下几种：
 // count > 0 : 从表头开始向表尾搜索，移除与 member 相等的元素，数量为 count 。
@@ -475,11 +2270,17 @@ func (c *Cacher) RPush(key string, member interface{}) error {
 // count = 0 : 移除表中所有与 member 相等的值。
 // 返回值：被移除元素的数量。
 func (c *Cacher) LREM(key string, count int, member interface{}) (int, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	return Int(c.Do("LREM", c.getKey(key), count, member))
 }
 
 // LLen 获取列表的长度
 func (c *Cacher) LLen(key string) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	return Int64(c.Do("RPOP", c.getKey(key)))
 }
 
@@ -488,9 +2289,54 @@ func (c *Cacher) LLen(key string) (int64, error) {
 // 你也可以使用负数下标，以 -1 表示列表的最后一个元素， -2 表示列表的倒数第二个元素，以此类推。
 // 和编程语言区间函数的区别：end 下标也在 LRANGE 命令的取值范围之内(闭区间)。
 func (c *Cacher) LRange(key string, start, end int) (interface{}, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	return c.Do("LRANGE", c.getKey(key), start, end)
 }
 
+// LRangeStrings LRange的工具方法，元素类型为string时
+func (c *Cacher) LRangeStrings(key string, start, end int) ([]string, error) {
+	return redis.Strings(c.LRange(key, start, end))
+}
+
+// LRangeInts LRange的工具方法，元素类型为int时
+func (c *Cacher) LRangeInts(key string, start, end int) ([]int, error) {
+	return redis.Ints(c.LRange(key, start, end))
+}
+
+/**
+Redis 的集合是string类型的无序集合，集合成员是唯一的，这就意味着集合中不能出现重复的数据。
+**/
+
+// SRandMember 从集合中随机返回 count 个成员，不会移除这些成员。
+// count 为正数时返回不重复的成员，数量不超过集合的成员数；count 为负数时返回 count 的绝对值个成员，成员可能重复。
+func (c *Cacher) SRandMember(key string, count int) ([]string, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	return redis.Strings(c.Do("SRANDMEMBER", c.getKey(key), count))
+}
+
+// SInterCard 返回给定集合交集的成员数量，不返回交集本身。需要redis 7.0及以上版本。
+// limit 大于0时，交集的数量达到 limit 就停止计算并返回 limit ，可以用于判断交集是否至少有 limit 个成员。
+func (c *Cacher) SInterCard(limit int, keys ...string) (int64, error) {
+	if err := c.checkSupport("SINTERCARD"); err != nil {
+		return 0, err
+	}
+	args := redis.Args{}.Add(len(keys))
+	for _, key := range keys {
+		if key == "" {
+			return 0, ErrEmptyKey
+		}
+		args = args.Add(c.getKey(key))
+	}
+	if limit > 0 {
+		args = args.Add("LIMIT", limit)
+	}
+	return Int64(c.Do("SINTERCARD", args...))
+}
+
 /**
 Redis 有序集合和集合一样也是string类型元素的集合,且不允许重复的成员。
 不同的是每个元素都会关联一个double类型的分数。redis正是通过分数来为集合中的成员进行从小到大的排序。
@@ -500,53 +2346,444 @@ Redis 有序集合和集合一样也是string类型元素的集合,且不允许
 
 // ZAdd 将一个 member 元素及其 score 值加入到有序集 key 当中。
 func (c *Cacher) ZAdd(key string, score int64, member string) (reply interface{}, err error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	return c.Do("ZADD", c.getKey(key), score, member)
 }
 
+// ZMember 有序集的成员及其分数
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAddMany 使用一条 ZADD 命令将多个成员及其分数加入到有序集 key 当中，返回新加入的成员数量（不包含更新了分数的已有成员）。
+func (c *Cacher) ZAddMany(key string, members []ZMember) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+	args := redis.Args{}.Add(c.getKey(key))
+	for _, m := range members {
+		args = args.Add(m.Score, m.Member)
+	}
+	return Int64(c.Do("ZADD", args...))
+}
+
+// FeedPush 将 item 与 Set 相同地序列化后作为成员加入有序集 key ，分数为 score （比如时间戳），用于按时间排序的信息流。
+// 有序集的成员是唯一的，序列化结果完全相同的 item 只会保存一个，分数以最后一次加入的为准。
+func (c *Cacher) FeedPush(key string, item interface{}, score float64) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	value, err := c.encode(item)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("ZADD", c.getKey(key), score, value)
+	return err
+}
+
+// FeedRange 按分数递增的顺序读取有序集 key 中下标从 start 到 stop 的成员（下标的用法与 ZRange 相同），
+// 使用与 Scan 相同的方式反序列化到 slicePtr 指向的切片中，并返回与切片元素一一对应的分数。
+// Example:
+//
+// ```golang
+// var posts []Post
+// scores, err := c.FeedRange("feed", 0, 9, &posts)
+// ```
+func (c *Cacher) FeedRange(key string, start, stop int64, slicePtr interface{}) ([]float64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	values, err := redis.Values(c.Do("ZRANGE", c.getKey(key), start, stop, "WITHSCORES"))
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, fmt.Errorf("%w: expects even number of values, got %d", ErrUnexpectedReply, len(values))
+	}
+	members := make([]interface{}, len(values)/2)
+	scores := make([]float64, len(values)/2)
+	for i := range members {
+		members[i] = values[2*i]
+		if scores[i], err = redis.Float64(values[2*i+1], nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Scan(members, nil, slicePtr); err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
 // ZRem 移除有序集 key 中的一个成员，不存在的成员将被忽略。
 func (c *Cacher) ZRem(key string, member string) (reply interface{}, err error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	return c.Do("ZREM", c.getKey(key), member)
 }
 
 // ZScore 返回有序集 key 中，成员 member 的 score 值。 如果 member 元素不是有序集 key 的成员，或 key 不存在，返回 nil 。
 func (c *Cacher) ZScore(key string, member string) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	return Int64(c.Do("ZSCORE", c.getKey(key), member))
 }
 
+// ZMScore 返回有序集 key 中多个成员的 score 值，结果与 members 一一对应，不存在的成员对应nil。需要redis 6.2及以上版本。
+func (c *Cacher) ZMScore(key string, members ...string) ([]*float64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if err := c.checkSupport("ZMSCORE"); err != nil {
+		return nil, err
+	}
+	values, err := redis.Values(c.Do("ZMSCORE", redis.Args{}.Add(c.getKey(key)).AddFlat(members)...))
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]*float64, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		score, err := redis.Float64(value, nil)
+		if err != nil {
+			return nil, err
+		}
+		scores[i] = &score
+	}
+	return scores, nil
+}
+
 // ZRank 返回有序集中指定成员的排名。其中有序集成员按分数值递增(从小到大)顺序排列。score 值最小的成员排名为 0
 func (c *Cacher) ZRank(key, member string) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	return Int64(c.Do("ZRANK", c.getKey(key), member))
 }
 
 // ZRevrank 返回有序集中成员的排名。其中有序集成员按分数值递减(从大到小)排序。分数值最大的成员排名为 0 。
 func (c *Cacher) ZRevrank(key, member string) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	return Int64(c.Do("ZREVRANK", c.getKey(key), member))
 }
 
 // ZRange 返回有序集中，指定区间内的成员。其中成员的位置按分数值递增(从小到大)来排序。具有相同分数值的成员按字典序(lexicographical order )来排列。
 // 以 0 表示有序集第一个成员，以 1 表示有序集第二个成员，以此类推。或 以 -1 表示最后一个成员， -2 表示倒数第二个成员，以此类推。
 func (c *Cacher) ZRange(key string, from, to int64) (map[string]int64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	return redis.Int64Map(c.Do("ZRANGE", c.getKey(key), from, to, "WITHSCORES"))
 }
 
 // ZRevrange 返回有序集中，指定区间内的成员。其中成员的位置按分数值递减(从大到小)来排列。具有相同分数值的成员按字典序(lexicographical order )来排列。
 // 以 0 表示有序集第一个成员，以 1 表示有序集第二个成员，以此类推。或 以 -1 表示最后一个成员， -2 表示倒数第二个成员，以此类推。
 func (c *Cacher) ZRevrange(key string, from, to int64) (map[string]int64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	return redis.Int64Map(c.Do("ZREVRANGE", c.getKey(key), from, to, "WITHSCORES"))
 }
 
 // ZRangeByScore 返回有序集合中指定分数区间的成员列表。有序集成员按分数值递增(从小到大)次序排列。
 // 具有相同分数值的成员按字典序来排列
+//
+// Deprecated: 返回的map没有顺序，分数也被截断为整数，并且无法表示开区间和无界区间，请使用 ZRangeByScoreOpts 。
 func (c *Cacher) ZRangeByScore(key string, from, to, offset int64, count int) (map[string]int64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	return redis.Int64Map(c.Do("ZRANGEBYSCORE", c.getKey(key), from, to, "WITHSCORES", "LIMIT", offset, count))
 }
 
+// ZRangeOpts ZRangeByScoreOpts 的查询参数
+type ZRangeOpts struct {
+	Min        string // 分数区间的下限，比如 "1.5"；以 "(" 开头表示不包含该值，比如 "(1.5"；"-inf" 表示无下限。为空时为 "-inf"
+	Max        string // 分数区间的上限，格式与 Min 相同，"+inf" 表示无上限。为空时为 "+inf"
+	WithScores bool   // 是否同时返回成员的分数，为 false 时 ZMember.Score 都为0
+	Offset     int64  // 跳过的成员数量，仅在 Count 大于0时有效
+	Count      int64  // 最多返回的成员数量，大于0时使用 LIMIT Offset Count
+}
+
+// ZRangeByScoreOpts 返回有序集中分数在 opts.Min 和 opts.Max 之间（默认包含边界）的成员，按分数值递增(从小到大)的顺序返回，
+// 具有相同分数值的成员按字典序来排列。分数可以使用 strconv.FormatFloat(score, 'f', -1, 64) 转换为字符串。
+func (c *Cacher) ZRangeByScoreOpts(key string, opts ZRangeOpts) ([]ZMember, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	min, max := opts.Min, opts.Max
+	if min == "" {
+		min = "-inf"
+	}
+	if max == "" {
+		max = "+inf"
+	}
+	args := redis.Args{}.Add(c.getKey(key), min, max)
+	if opts.WithScores {
+		args = args.Add("WITHSCORES")
+	}
+	if opts.Count > 0 {
+		args = args.Add("LIMIT", opts.Offset, opts.Count)
+	}
+	values, err := redis.Values(c.Do("ZRANGEBYSCORE", args...))
+	if err != nil {
+		return nil, err
+	}
+	return parseZMembers(values, opts.WithScores)
+}
+
 // ZRevrangeByScore 返回有序集中指定分数区间内的所有的成员。有序集成员按分数值递减(从大到小)的次序排列。
 // 具有相同分数值的成员按字典序来排列
 func (c *Cacher) ZRevrangeByScore(key string, from, to, offset int64, count int) (map[string]int64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	return redis.Int64Map(c.Do("ZREVRANGEBYSCORE", c.getKey(key), from, to, "WITHSCORES", "LIMIT", offset, count))
 }
 
+// ZUnionStore 计算给定的一个或多个有序集的并集，并将结果储存到 dest 。返回结果集中的成员数量。
+// weights 为每个有序集的乘法因子，为空时默认都为1；aggregate 为结果集的聚合方式，可以是 SUM（默认）、MIN 或 MAX。
+func (c *Cacher) ZUnionStore(dest string, keys []string, weights []float64, aggregate string) (int64, error) {
+	return c.zStore("ZUNIONSTORE", dest, keys, weights, aggregate)
+}
+
+// ZInterStore 计算给定的一个或多个有序集的交集，并将结果储存到 dest 。返回结果集中的成员数量。
+// weights 和 aggregate 的用法与 ZUnionStore 相同。
+func (c *Cacher) ZInterStore(dest string, keys []string, weights []float64, aggregate string) (int64, error) {
+	return c.zStore("ZINTERSTORE", dest, keys, weights, aggregate)
+}
+
+// ZRangeStore 将有序集 src 中指定区间内的成员储存到 dest ，返回 dest 中的成员数量。需要redis 6.2及以上版本。
+// byScore 为 false 时 start 和 stop 为下标，为 true 时为分数区间；rev 为 true 时按分数值递减排列，
+// 此时按分数查询的 start 应为较大的分数，stop 为较小的分数。
+func (c *Cacher) ZRangeStore(dest, src string, start, stop int64, byScore bool, rev bool) (int64, error) {
+	if dest == "" || src == "" {
+		return 0, ErrEmptyKey
+	}
+	if err := c.checkSupport("ZRANGESTORE"); err != nil {
+		return 0, err
+	}
+	args := redis.Args{}.Add(c.getKey(dest), c.getKey(src), start, stop)
+	if byScore {
+		args = args.Add("BYSCORE")
+	}
+	if rev {
+		args = args.Add("REV")
+	}
+	return Int64(c.Do("ZRANGESTORE", args...))
+}
+
+// ZDiff 返回第一个有序集与其后各有序集的差集，即只存在于 keys[0] 而不存在于其他有序集中的成员。需要redis 6.2及以上版本。
+// withScores 为 true 时同时返回成员在 keys[0] 中的分数，否则 ZMember.Score 都为0。
+func (c *Cacher) ZDiff(keys []string, withScores bool) ([]ZMember, error) {
+	if len(keys) == 0 {
+		return nil, ErrEmptyKey
+	}
+	if err := c.checkSupport("ZDIFF"); err != nil {
+		return nil, err
+	}
+	args := redis.Args{}.Add(len(keys))
+	for _, key := range keys {
+		if key == "" {
+			return nil, ErrEmptyKey
+		}
+		args = args.Add(c.getKey(key))
+	}
+	if withScores {
+		args = args.Add("WITHSCORES")
+	}
+	values, err := redis.Values(c.Do("ZDIFF", args...))
+	if err != nil {
+		return nil, err
+	}
+	return parseZMembers(values, withScores)
+}
+
+// ZDiffStore 计算第一个有序集与其后各有序集的差集，并将结果储存到 dest ，返回结果集中的成员数量。需要redis 6.2及以上版本。
+func (c *Cacher) ZDiffStore(dest string, keys []string) (int64, error) {
+	if dest == "" || len(keys) == 0 {
+		return 0, ErrEmptyKey
+	}
+	if err := c.checkSupport("ZDIFFSTORE"); err != nil {
+		return 0, err
+	}
+	args := redis.Args{}.Add(c.getKey(dest), len(keys))
+	for _, key := range keys {
+		if key == "" {
+			return 0, ErrEmptyKey
+		}
+		args = args.Add(c.getKey(key))
+	}
+	return Int64(c.Do("ZDIFFSTORE", args...))
+}
+
+// parseZMembers 解析 ZDIFF 等命令返回的成员列表，withScores 为 true 时成员与分数交替出现
+func parseZMembers(values []interface{}, withScores bool) ([]ZMember, error) {
+	step := 1
+	if withScores {
+		step = 2
+		if len(values)%2 != 0 {
+			return nil, fmt.Errorf("%w: expects even number of values, got %d", ErrUnexpectedReply, len(values))
+		}
+	}
+	members := make([]ZMember, 0, len(values)/step)
+	for i := 0; i < len(values); i += step {
+		member, err := redis.String(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		m := ZMember{Member: member}
+		if withScores {
+			if m.Score, err = redis.Float64(values[i+1], nil); err != nil {
+				return nil, err
+			}
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// zStore ZUNIONSTORE 和 ZINTERSTORE 的实现
+func (c *Cacher) zStore(commandName, dest string, keys []string, weights []float64, aggregate string) (int64, error) {
+	if dest == "" {
+		return 0, ErrEmptyKey
+	}
+	if len(weights) > 0 && len(weights) != len(keys) {
+		return 0, fmt.Errorf("redisgo: %s: got %d weights for %d keys", commandName, len(weights), len(keys))
+	}
+	args := redis.Args{}.Add(c.getKey(dest), len(keys))
+	for _, key := range keys {
+		if key == "" {
+			return 0, ErrEmptyKey
+		}
+		args = args.Add(c.getKey(key))
+	}
+	if len(weights) > 0 {
+		args = args.Add("WEIGHTS").AddFlat(weights)
+	}
+	if aggregate != "" {
+		args = args.Add("AGGREGATE", aggregate)
+	}
+	return Int64(c.Do(commandName, args...))
+}
+
+/**
+Redis 事务使用 MULTI/EXEC 一次执行多条命令，配合 WATCH 实现乐观锁。
+**/
+
+// WithConn 从连接池获取一个连接传给 fn ，fn 返回后放回连接池。用于需要在同一个连接上执行多条命令的场景，比如 WATCH 和 MULTI/EXEC 。
+// 通过 conn 执行的命令不会加上键名前缀，也不会触发 OnCommand 回调，可以使用 Watch 等方法或 Key 为键名加上前缀。
+// fn 返回错误或panic时，连接可能停留在 MULTI 或者 SELECT 了其他数据库等状态，放回连接池之前会使用 ResetConn 恢复连接的状态。
+func (c *Cacher) WithConn(fn func(conn redis.Conn) error) (err error) {
+	conn := c.getConn()
+	defer conn.Close()
+	defer func() {
+		if p := recover(); p != nil {
+			c.ResetConn(conn)
+			panic(p)
+		}
+		if err != nil {
+			c.ResetConn(conn)
+		}
+	}()
+	return fn(conn)
+}
+
+// ResetConn 将连接恢复到刚从连接池建立时的状态，用于 WithConn 等直接使用连接、可能改变了连接状态的场景。
+// redis 6.2及以上版本使用 RESET 命令（放弃 MULTI 事务、取消 WATCH 和订阅、恢复默认数据库等），之后重新执行鉴权、
+// SELECT 数据库和设置连接名称；低版本只重新 SELECT 数据库，放回连接池时redigo会取消事务、监视和订阅。
+func (c *Cacher) ResetConn(conn redis.Conn) error {
+	state := c.state()
+	if !c.Supports("RESET") {
+		_, err := conn.Do("SELECT", state.opts.Db)
+		return wrapError("SELECT", err)
+	}
+	if _, err := conn.Do("RESET"); err != nil {
+		return wrapError("RESET", err)
+	}
+	return wrapError("RESET", state.setup(conn))
+}
+
+// Watch 在 conn 上监视键（键名会加上前缀），之后 conn 上的 EXEC 在这些键被其他客户端修改时返回nil，事务不执行。
+// WATCH、读取键值和 MULTI/EXEC 必须在同一个连接上执行，所以应该在 WithConn 中使用。
+// Example:
+//
+// ```golang
+// err := c.WithConn(func(conn redis.Conn) error {
+// if err := c.Watch(conn, "balance"); err != nil {
+// return err
+// }
+// balance, err := redis.Int64(conn.Do("GET", c.Key("balance")))
+// // ... 根据balance决定是否继续，不继续时调用 c.Unwatch(conn)
+// conn.Send("MULTI")
+// conn.Send("SET", c.Key("balance"), balance-10)
+// reply, err := conn.Do("EXEC") // 键被修改时 reply 为nil
+// return err
+// })
+// ```
+func (c *Cacher) Watch(conn redis.Conn, keys ...string) error {
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if key == "" {
+			return ErrEmptyKey
+		}
+		args[i] = c.getKey(key)
+	}
+	_, err := conn.Do("WATCH", args...)
+	return wrapError("WATCH", err)
+}
+
+// Unwatch 取消 conn 上所有键的监视。EXEC 或 DISCARD 之后会自动取消，连接放回连接池时也会自动取消。
+func (c *Cacher) Unwatch(conn redis.Conn) error {
+	_, err := conn.Do("UNWATCH")
+	return wrapError("UNWATCH", err)
+}
+
+/**
+Redis 使用Lua解释器执行脚本，脚本的执行是原子性的。
+**/
+
+// Eval 执行Lua脚本并返回结果。keys中的键名会加上前缀，script的keyCount应该与keys的数量一致。
+// 执行时优先使用EVALSHA，脚本未加载时自动改用EVAL。
+// Example:
+//
+// ```golang
+// script := redis.NewScript(1, `return redis.call("GET", KEYS[1])`)
+// reply, err := c.Eval(script, []string{"name"})
+// ```
+func (c *Cacher) Eval(script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	keysAndArgs := make([]interface{}, 0, len(keys)+len(args))
+	for _, key := range keys {
+		if key == "" {
+			return nil, ErrEmptyKey
+		}
+		keysAndArgs = append(keysAndArgs, c.getKey(key))
+	}
+	keysAndArgs = append(keysAndArgs, args...)
+	defer c.evictLocal("EVALSHA", keysAndArgs[:len(keys)]...)
+	conn := c.getConn()
+	defer conn.Close()
+	reply, err := script.Do(conn, keysAndArgs...)
+	return reply, wrapError("EVALSHA", err)
+}
+
+// EvalObject 执行Lua脚本，并将结果反序列化到dest中。脚本必须返回单个字符串（bulk string），比如用cjson.encode序列化的对象。
+func (c *Cacher) EvalObject(script *redis.Script, keys []string, dest interface{}, args ...interface{}) error {
+	reply, err := c.Eval(script, keys, args...)
+	return c.decode(reply, err, dest)
+}
+
 /**
 Redis 发布订阅(pub/sub)是一种消息通信模式：发送者(pub)发送消息，订阅者(sub)接收消息。
 Redis 客户端可以订阅任意数量的频道。
@@ -558,19 +2795,83 @@ func (c *Cacher) Publish(channel, message string) (int, error) {
 	return Int(c.Do("PUBLISH", channel, message))
 }
 
+// PubSubChannels 使用 PUBSUB CHANNELS 返回当前至少有一个订阅者的频道，pattern 为空时返回所有频道，否则只返回匹配的频道。
+// 只统计 SUBSCRIBE 订阅的频道，不包含 PSUBSCRIBE 的模式订阅。
+func (c *Cacher) PubSubChannels(pattern string) ([]string, error) {
+	args := redis.Args{}.Add("CHANNELS")
+	if pattern != "" {
+		args = args.Add(pattern)
+	}
+	return redis.Strings(c.Do("PUBSUB", args...))
+}
+
+// PubSubNumSub 使用 PUBSUB NUMSUB 返回每个频道的订阅者数量，没有订阅者的频道数量为0。
+func (c *Cacher) PubSubNumSub(channels ...string) (map[string]int64, error) {
+	return redis.Int64Map(c.Do("PUBSUB", redis.Args{}.Add("NUMSUB").AddFlat(channels)...))
+}
+
 // Subscribe 订阅给定的一个或多个频道的信息。
 // 支持redis服务停止或网络异常等情况时，自动重新订阅。
 // 一般的程序都是启动后开启一些固定channel的订阅，也不会动态的取消订阅，这种场景下可以使用本方法。
 // 复杂场景的使用可以直接参考 https://godoc.org/github.com/gomodule/redigo/redis#hdr-Publish_and_Subscribe
 func (c *Cacher) Subscribe(onMessage func(channel string, data []byte) error, channels ...string) error {
-	conn := c.pool.Get()
+	return c.SubscribeWithOptions(onMessage, SubscribeOptions{}, channels...)
+}
+
+// SubscribeTyped 订阅 channel 频道，每条消息使用与 GetObject 相同的方式（配置的反序列化方法、格式标记和解压）反序列化到
+// newEvent 返回的新对象（应该返回指针）中，再交给 handler 处理，发布时应该使用与 Set 相同的序列化方法。
+// 与 Subscribe 一样自动重新订阅，每条消息在单独的goroutine中处理；反序列化失败的消息会被丢弃，handler 返回的错误也会被忽略，
+// 需要记录时应该在 handler 中处理。
+// Example:
+//
+// ```golang
+// err := c.SubscribeTyped("orders", func() interface{} { return &Order{} }, func(ev interface{}) error {
+// order := ev.(*Order)
+// return nil
+// })
+// ```
+func (c *Cacher) SubscribeTyped(channel string, newEvent func() interface{}, handler func(ev interface{}) error) error {
+	return c.Subscribe(func(_ string, data []byte) error {
+		ev := newEvent()
+		if err := c.decode(data, nil, ev); err != nil {
+			return err
+		}
+		return handler(ev)
+	}, channel)
+}
+
+// SubscribeOptions 订阅的参数
+type SubscribeOptions struct {
+	OnReconnect            func()          // 连接断开后重新订阅成功时的回调。发布订阅的消息不会持久化，断开期间发布的消息都会丢失，可以在回调中重新同步数据，比如清空本地缓存
+	MaxResubscribeAttempts int             // 连续订阅失败的最大次数，达到后不再重试。默认为0，表示一直重试
+	OnGiveUp               func(err error) // 连接断开后重新订阅的失败次数达到 MaxResubscribeAttempts 、放弃订阅时的回调，err 为最后一次订阅的错误，可以用于告警
+}
+
+// SubscribeWithOptions 与 Subscribe 相同，可以通过 opts 设置重新订阅等情况的回调。
+// 设置了 MaxResubscribeAttempts 时，首次订阅连续失败达到次数后返回错误；之后连接断开、重新订阅失败达到次数时调用 OnGiveUp 。
+func (c *Cacher) SubscribeWithOptions(onMessage func(channel string, data []byte) error, opts SubscribeOptions, channels ...string) error {
+	return c.subscribe(onMessage, opts, false, 0, channels...)
+}
+
+// subscribe 订阅频道，reconnect 表示是否为断开后的重新订阅，attempts 为之前连续订阅失败的次数
+func (c *Cacher) subscribe(onMessage func(channel string, data []byte) error, opts SubscribeOptions, reconnect bool, attempts int, channels ...string) error {
+	conn := c.getConn()
 	psc := redis.PubSubConn{Conn: conn}
 	err := psc.Subscribe(redis.Args{}.AddFlat(channels)...)
 	// 如果订阅失败，休息1秒后重新订阅（比如当redis服务停止服务或网络异常）
 	if err != nil {
 		fmt.Println(err)
+		psc.Close()
+		attempts++
+		if opts.MaxResubscribeAttempts > 0 && attempts >= opts.MaxResubscribeAttempts {
+			err = fmt.Errorf("redisgo: Subscribe: gave up after %d attempts: %w", attempts, err)
+			if reconnect && opts.OnGiveUp != nil {
+				opts.OnGiveUp(err)
+			}
+			return err
+		}
 		time.Sleep(time.Second)
-		return c.Subscribe(onMessage, channels...)
+		return c.subscribe(onMessage, opts, reconnect, attempts, channels...)
 	}
 	quit := make(chan int, 1)
 
@@ -583,9 +2884,16 @@ func (c *Cacher) Subscribe(onMessage func(channel string, data []byte) error, ch
 				go onMessage(v.Channel, v.Data)
 			case redis.Subscription:
 				fmt.Printf("%s: %s %d\n", v.Channel, v.Kind, v.Count)
+				// 收到订阅确认后才认为重新订阅成功
+				if reconnect {
+					reconnect = false
+					if opts.OnReconnect != nil {
+						opts.OnReconnect()
+					}
+				}
 			case error:
 				quit <- 1
-				fmt.Println(err)
+				fmt.Println(v)
 				return
 			}
 		}
@@ -596,7 +2904,7 @@ func (c *Cacher) Subscribe(onMessage func(channel string, data []byte) error, ch
 		<-quit
 		time.Sleep(time.Second)
 		psc.Close()
-		c.Subscribe(onMessage, channels...)
+		c.subscribe(onMessage, opts, true, 0, channels...)
 	}()
 	return err
 }
@@ -612,6 +2920,8 @@ type GeoOptions struct {
 	WithHash  bool
 	Order     string // ASC从近到远，DESC从远到近
 	Count     int
+	Store     string // 将结果储存到指定的有序集中，分数为位置的geohash，仅用于 GeoRadiusStore 和 GeoRadiusByMemberStore
+	StoreDist string // 将结果储存到指定的有序集中，分数为与中心的距离，仅用于 GeoRadiusStore 和 GeoRadiusByMemberStore
 }
 
 // GeoResult 用于GEORADIUS和GEORADIUSBYMEMBER命令的查询结果
@@ -625,18 +2935,50 @@ type GeoResult struct {
 
 // GeoAdd 将给定的空间元素（纬度、经度、名字）添加到指定的键里面，这些数据会以有序集合的形式被储存在键里面，所以删除可以使用`ZREM`。
 func (c *Cacher) GeoAdd(key string, longitude, latitude float64, member string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
 	_, err := redis.Int(c.Do("GEOADD", c.getKey(key), longitude, latitude, member))
 	return err
 }
 
 // GeoPos 从键里面返回所有给定位置元素的位置（经度和纬度）。
+//
+// Deprecated: 返回的数组无法区分经度和纬度，请使用 GeoPositions 。
 func (c *Cacher) GeoPos(key string, members ...interface{}) ([]*[2]float64, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	args := redis.Args{}
 	args = args.Add(c.getKey(key))
 	args = args.Add(members...)
 	return redis.Positions(c.Do("GEOPOS", args...))
 }
 
+// GeoPoint 位置元素的经纬度
+type GeoPoint struct {
+	Longitude float64
+	Latitude  float64
+}
+
+// GeoPositions 返回给定位置元素的经纬度，结果与 members 一一对应，不存在的元素对应nil。
+func (c *Cacher) GeoPositions(key string, members ...string) ([]*GeoPoint, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	positions, err := redis.Positions(c.Do("GEOPOS", redis.Args{}.Add(c.getKey(key)).AddFlat(members)...))
+	if err != nil {
+		return nil, err
+	}
+	points := make([]*GeoPoint, len(positions))
+	for i, p := range positions {
+		if p != nil {
+			points[i] = &GeoPoint{Longitude: p[0], Latitude: p[1]}
+		}
+	}
+	return points, nil
+}
+
 // GeoDist 返回两个给定位置之间的距离。
 // 如果两个位置之间的其中一个不存在， 那么命令返回空值。
 // 指定单位的参数 unit 必须是以下单位的其中一个：
@@ -646,29 +2988,21 @@ func (c *Cacher) GeoPos(key string, members ...interface{}) ([]*[2]float64, erro
 // ft 表示单位为英尺。
 // 如果用户没有显式地指定单位参数， 那么 GEODIST 默认使用米作为单位。
 func (c *Cacher) GeoDist(key string, member1, member2, unit string) (float64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	_, err := redis.Float64(c.Do("GEODIST", c.getKey(key), member1, member2, unit))
 	return 0, err
 }
 
 // GeoRadius 以给定的经纬度为中心， 返回键包含的位置元素当中， 与中心的距离不超过给定最大距离的所有位置元素。
 func (c *Cacher) GeoRadius(key string, longitude, latitude, radius float64, unit string, options GeoOptions) ([]*GeoResult, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	args := redis.Args{}
 	args = args.Add(c.getKey(key), longitude, latitude, radius, unit)
-	if options.WithDist {
-		args = args.Add("WITHDIST")
-	}
-	if options.WithCoord {
-		args = args.Add("WITHCOORD")
-	}
-	if options.WithHash {
-		args = args.Add("WITHHASH")
-	}
-	if options.Order != "" {
-		args = args.Add(options.Order)
-	}
-	if options.Count > 0 {
-		args = args.Add("Count", options.Count)
-	}
+	args = geoRadiusArgs(args, options)
 
 	reply, err := c.Do("GEORADIUS", args...)
 	return toGeoResult(reply, err, options)
@@ -676,8 +3010,106 @@ func (c *Cacher) GeoRadius(key string, longitude, latitude, radius float64, unit
 
 // GeoRadiusByMember 这个命令和 GEORADIUS 命令一样， 都可以找出位于指定范围内的元素， 但是 GEORADIUSBYMEMBER 的中心点是由给定的位置元素决定的， 而不是像 GEORADIUS 那样， 使用输入的经度和纬度来决定中心点。
 func (c *Cacher) GeoRadiusByMember(key string, member string, radius float64, unit string, options GeoOptions) ([]*GeoResult, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	args := redis.Args{}
+	args = args.Add(c.getKey(key), member, radius, unit)
+	args = geoRadiusArgs(args, options)
+
+	reply, err := c.Do("GEORADIUSBYMEMBER", args...)
+	return toGeoResult(reply, err, options)
+}
+
+// GeoRadiusStore 与 GeoRadius 相同，但是将结果储存到 options.Store 或 options.StoreDist 指定的有序集中，返回储存的元素数量。
+// 储存时不能使用 WithCoord、WithDist 和 WithHash 。
+func (c *Cacher) GeoRadiusStore(key string, longitude, latitude, radius float64, unit string, options GeoOptions) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	args := redis.Args{}
+	args = args.Add(c.getKey(key), longitude, latitude, radius, unit)
+	args, err := c.geoStoreArgs(geoRadiusArgs(args, options), options)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(c.Do("GEORADIUS", args...))
+}
+
+// GeoRadiusByMemberStore 与 GeoRadiusByMember 相同，但是将结果储存到 options.Store 或 options.StoreDist 指定的有序集中，返回储存的元素数量。
+func (c *Cacher) GeoRadiusByMemberStore(key string, member string, radius float64, unit string, options GeoOptions) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
 	args := redis.Args{}
 	args = args.Add(c.getKey(key), member, radius, unit)
+	args, err := c.geoStoreArgs(geoRadiusArgs(args, options), options)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(c.Do("GEORADIUSBYMEMBER", args...))
+}
+
+// GeoSearchOptions 用于GEOSEARCH和GEOSEARCHSTORE命令的参数
+type GeoSearchOptions struct {
+	FromMember string  // 以有序集中的成员为中心，为空时以 Longitude 和 Latitude 为中心
+	Longitude  float64 // 中心的经度，仅在 FromMember 为空时使用
+	Latitude   float64 // 中心的纬度，仅在 FromMember 为空时使用
+	Radius     float64 // 大于0时按圆形范围（BYRADIUS）查询
+	Width      float64 // Radius 为0时按矩形范围（BYBOX）查询的宽度
+	Height     float64 // Radius 为0时按矩形范围（BYBOX）查询的高度
+	Unit       string  // 距离单位，m、km、mi 或 ft ，默认为 m
+	Order      string  // ASC从近到远，DESC从远到近
+	Count      int     // 大于0时最多返回的元素数量
+	Any        bool    // 与 Count 一起使用，找到足够数量的元素后立即返回，结果不一定是最近的
+}
+
+// GeoSearchStore 使用 GEOSEARCHSTORE 将 src 中符合 opts 的元素储存到有序集 dest 中，返回储存的元素数量，需要redis 6.2及以上版本。
+// storeDist 为 true 时储存的分数为与中心的距离，否则为位置的geohash，可以继续使用 GeoPos 等方法查询。
+func (c *Cacher) GeoSearchStore(dest, src string, opts GeoSearchOptions, storeDist bool) (int64, error) {
+	if dest == "" || src == "" {
+		return 0, ErrEmptyKey
+	}
+	if err := c.checkSupport("GEOSEARCHSTORE"); err != nil {
+		return 0, err
+	}
+	args := geoSearchArgs(redis.Args{}.Add(c.getKey(dest), c.getKey(src)), opts)
+	if storeDist {
+		args = args.Add("STOREDIST")
+	}
+	return Int64(c.Do("GEOSEARCHSTORE", args...))
+}
+
+// geoSearchArgs 添加GEOSEARCH和GEOSEARCHSTORE命令的查询参数
+func geoSearchArgs(args redis.Args, opts GeoSearchOptions) redis.Args {
+	unit := opts.Unit
+	if unit == "" {
+		unit = "m"
+	}
+	if opts.FromMember != "" {
+		args = args.Add("FROMMEMBER", opts.FromMember)
+	} else {
+		args = args.Add("FROMLONLAT", opts.Longitude, opts.Latitude)
+	}
+	if opts.Radius > 0 {
+		args = args.Add("BYRADIUS", opts.Radius, unit)
+	} else {
+		args = args.Add("BYBOX", opts.Width, opts.Height, unit)
+	}
+	if opts.Order != "" {
+		args = args.Add(opts.Order)
+	}
+	if opts.Count > 0 {
+		args = args.Add("COUNT", opts.Count)
+		if opts.Any {
+			args = args.Add("ANY")
+		}
+	}
+	return args
+}
+
+// geoRadiusArgs 添加GEORADIUS和GEORADIUSBYMEMBER命令的查询参数
+func geoRadiusArgs(args redis.Args, options GeoOptions) redis.Args {
 	if options.WithDist {
 		args = args.Add("WITHDIST")
 	}
@@ -693,13 +3125,28 @@ func (c *Cacher) GeoRadiusByMember(key string, member string, radius float64, un
 	if options.Count > 0 {
 		args = args.Add("Count", options.Count)
 	}
+	return args
+}
 
-	reply, err := c.Do("GEORADIUSBYMEMBER", args...)
-	return toGeoResult(reply, err, options)
+// geoStoreArgs 添加GEORADIUS和GEORADIUSBYMEMBER命令的STORE和STOREDIST参数，储存的键名会加上前缀
+func (c *Cacher) geoStoreArgs(args redis.Args, options GeoOptions) (redis.Args, error) {
+	if options.Store == "" && options.StoreDist == "" {
+		return nil, fmt.Errorf("redisgo: Store or StoreDist is required")
+	}
+	if options.Store != "" {
+		args = args.Add("STORE", c.getKey(options.Store))
+	}
+	if options.StoreDist != "" {
+		args = args.Add("STOREDIST", c.getKey(options.StoreDist))
+	}
+	return args, nil
 }
 
 // GeoHash 返回一个或多个位置元素的 Geohash 表示。
 func (c *Cacher) GeoHash(key string, members ...interface{}) ([]string, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
 	args := redis.Args{}
 	args = args.Add(c.getKey(key))
 	args = args.Add(members...)
@@ -718,7 +3165,7 @@ func toGeoResult(reply interface{}, err error, options GeoOptions) ([]*GeoResult
 		}
 		p, ok := values[i].([]interface{})
 		if !ok {
-			return nil, fmt.Errorf("redisgo: unexpected element type for interface slice, got type %T", values[i])
+			return nil, fmt.Errorf("%w: unexpected element type for interface slice, got type %T", ErrUnexpectedReply, values[i])
 		}
 		geoResult := &GeoResult{}
 		pos := 0
@@ -747,7 +3194,7 @@ func toGeoResult(reply interface{}, err error, options GeoOptions) ([]*GeoResult
 			pos = pos + 1
 			pp, ok := p[pos].([]interface{})
 			if !ok {
-				return nil, fmt.Errorf("redisgo: unexpected element type for interface slice, got type %T", p[i])
+				return nil, fmt.Errorf("%w: unexpected element type for interface slice, got type %T", ErrUnexpectedReply, p[i])
 			}
 			if len(pp) > 0 {
 				lat, err := redis.Float64(pp[0], nil)
@@ -770,34 +3217,148 @@ func toGeoResult(reply interface{}, err error, options GeoOptions) ([]*GeoResult
 	return results, nil
 }
 
-// getKey 将健名加上指定的前缀。
+// globChars SCAN 等命令的匹配模式中有特殊含义的字符，前缀中包含这些字符时会匹配到其他前缀的键
+const globChars = "*?[]\\"
+
+// joinPrefix 为非空的前缀加上分隔符
+func (c *Cacher) joinPrefix(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	return prefix + c.prefixSeparator
+}
+
+// Key 返回加上前缀后的键名，用于通过 Do 或 WithConn 的连接直接执行命令时与其他方法使用相同的键名
+func (c *Cacher) Key(key string) string {
+	return c.getKey(key)
+}
+
+// StripPrefix 去掉完整键名中的前缀，返回其他方法使用的逻辑键名，与 Key 相反。键名不是以前缀开头时原样返回。
+func (c *Cacher) StripPrefix(key string) string {
+	return c.stripKey(key)
+}
+
+// getKey 将健名加上指定的前缀，设置了 KeyRouter 时使用其返回的前缀。按字节拼接，二进制键名也可以正确处理。
 func (c *Cacher) getKey(key string) string {
+	if c.keyRouter != nil {
+		prefix, _ := c.keyRouter(key)
+		return c.joinPrefix(prefix) + key
+	}
 	return c.prefix + key
 }
 
-// encode 序列化要保存的值
+// stripKey 去掉键名的前缀，与 getKey 相反。设置了 KeyRouter 时，依次尝试以每个分隔符后的部分作为键名，
+// 选择 KeyRouter 为其返回的前缀正好是去掉的部分的那个。
+func (c *Cacher) stripKey(key string) string {
+	if c.keyRouter == nil {
+		return strings.TrimPrefix(key, c.prefix)
+	}
+	if c.getKey(key) == key {
+		return key
+	}
+	for i := 0; c.prefixSeparator != ""; {
+		n := strings.Index(key[i:], c.prefixSeparator)
+		if n < 0 {
+			break
+		}
+		i += n + len(c.prefixSeparator)
+		if c.getKey(key[i:]) == key {
+			return key[i:]
+		}
+	}
+	return key
+}
+
+// jitter 按 TTLJitter 随机调整有效期，expire 的单位为秒，结果至少为1
+func (c *Cacher) jitter(expire int64) int64 {
+	if c.ttlJitter <= 0 || expire <= 0 {
+		return expire
+	}
+	expire += int64(math.Round(float64(expire) * c.ttlJitter * (2*rand.Float64() - 1)))
+	if expire < 1 {
+		expire = 1
+	}
+	return expire
+}
+
+// encode 序列化要保存的值。基础类型和[]byte直接交给redigo转换；其他类型序列化后以[]byte返回，redigo可以直接写入，不需要再复制为string。
 func (c *Cacher) encode(val interface{}) (interface{}, error) {
 	var value interface{}
 	switch v := val.(type) {
-	case string, int, uint, int8, int16, int32, int64, float32, float64, bool:
+	case string, []byte, int, uint, int8, int16, int32, int64, float32, float64, bool:
 		value = v
 	default:
 		b, err := c.marshal(v)
 		if err != nil {
 			return nil, err
 		}
-		value = string(b)
+		if c.formatTag != 0 {
+			b = append([]byte{c.formatTag}, b...)
+		}
+		if c.compress && len(b) >= c.compressMinBytes {
+			if b, err = gzipCompress(b); err != nil {
+				return nil, err
+			}
+		}
+		value = b
 	}
 	return value, nil
 }
 
-// decode 反序列化保存的struct对象
+// decode 反序列化保存的struct对象。压缩过的数据会先解压。
+// 设置了 FormatTag 时，根据数据的第一个字节选择反序列化方法，没有格式标记的数据使用 Unmarshalers[0] 或 Unmarshal 反序列化。
+// 开启了压缩时，以gzip标记开头的数据一定是压缩过的；没有开启压缩时，只有能够成功解压的才视为压缩过的数据，
+// 以便关闭压缩后仍然可以读取压缩过的数据，同时不会误解压恰好以gzip标记开头的其他数据。
+// 默认的json反序列化使用复用的json.Decoder；解压使用复用的缓冲区，其他反序列化方法传入的是复制后的数据。
 func (c *Cacher) decode(reply interface{}, err error, val interface{}) error {
-	str, err := String(reply, err)
+	b, err := redis.Bytes(reply, err)
 	if err != nil {
 		return err
 	}
-	return c.unmarshal([]byte(str), val)
+	data := b
+	pooled := false
+	if bytes.HasPrefix(b, gzipMagic) {
+		// 使用默认的json反序列化时直接从解压流中读取，不需要先解压到缓冲区
+		if c.compress && c.formatTag == 0 && c.jsonUnmarshal {
+			r, release, err := gzipReader(b)
+			if err != nil {
+				return err
+			}
+			defer release()
+			return decodeJSON(r, val)
+		}
+		unzipped, release, err := gzipDecompress(b)
+		if err == nil {
+			defer release()
+			data = unzipped
+			pooled = true
+		} else if c.compress {
+			return err
+		}
+	}
+	unmarshal := c.unmarshal
+	retains := !c.jsonUnmarshal
+	if c.formatTag != 0 && len(data) > 0 {
+		if data[0] == c.formatTag {
+			data = data[1:]
+		} else if u, ok := c.unmarshalers[data[0]]; ok && data[0] != 0 {
+			unmarshal = u
+			retains = true
+			data = data[1:]
+		} else if u, ok := c.unmarshalers[0]; ok {
+			unmarshal = u
+			retains = true
+		}
+	}
+	if !retains {
+		// 默认的json反序列化使用复用的json.Decoder，不需要每次分配解码状态
+		return decodeJSONBytes(data, val)
+	}
+	// 缓冲区放回后会被覆盖，自定义的反序列化方法可能在返回后仍然引用传入的数据，所以传入复制后的数据
+	if pooled {
+		data = append([]byte(nil), data...)
+	}
+	return unmarshal(data, val)
 }
 
 // closePool 程序进程退出时关闭连接池
@@ -808,7 +3369,7 @@ func (c *Cacher) closePool() {
 	signal.Notify(ch, syscall.SIGKILL)
 	go func() {
 		<-ch
-		c.pool.Close()
+		c.Close()
 		os.Exit(0)
 	}()
 }
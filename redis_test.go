@@ -1,9 +1,24 @@
 package redisgo
 
 import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/gomodule/redigo/redis"
 )
 
 type User struct {
@@ -31,9 +46,24 @@ func Equal(t *testing.T, expected, actual interface{}) {
 	}
 }
 
+// testServer 测试使用的内存redis服务
+var testServer *miniredis.Miniredis
+
+func TestMain(m *testing.M) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	testServer = s
+	code := m.Run()
+	s.Close()
+	os.Exit(code)
+}
+
 func getCacher() *Cacher {
 	c, err := New(
 		Options{
+			Addr:   testServer.Addr(),
 			Prefix: "zengate_",
 		})
 	if err != nil {
@@ -42,6 +72,124 @@ func getCacher() *Cacher {
 	return c
 }
 
+// getHookedCacher 启动单独的miniredis服务，使用 options 创建连接到该服务的Cacher（Addr 会被替换为该服务的地址，不监听退出信号），
+// 测试结束时关闭Cacher和服务。
+// hook 不为nil时在服务执行每条命令前调用，返回 true 表示已经写入了回复，用于模拟miniredis没有实现的命令或者特定的回复。
+func getHookedCacher(t *testing.T, options Options, hook func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool) (*Cacher, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	if hook != nil {
+		s.Server().SetPreHook(func(p *server.Peer, cmd string, args ...string) bool {
+			return hook(s, p, cmd, args...)
+		})
+	}
+	options.Addr = s.Addr()
+	options.DisableSignalClose = true
+	c, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, s
+}
+
+// getVersionCacher 使用 getHookedCacher 创建Cacher，服务的 INFO server 回复的redis版本为 version ，用于测试 checkSupport 。
+// miniredis没有实现 INFO server ，连接到 testServer 的Cacher获取不到版本，Supports 总是返回 true 。
+func getVersionCacher(t *testing.T, options Options, version string) *Cacher {
+	c, _ := getHookedCacher(t, options, func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool {
+		return writeServerInfo(p, cmd, version)
+	})
+	return c
+}
+
+// writeServerInfo 命令为 INFO 时写入redis版本为 version 的回复并返回 true ，用于在其他钩子中模拟服务版本
+func writeServerInfo(p *server.Peer, cmd string, version string) bool {
+	if cmd != "INFO" {
+		return false
+	}
+	p.WriteBulk("# Server\r\nredis_version:" + version + "\r\n")
+	return true
+}
+
+// debugSleep 使用 DEBUG SLEEP 让redis服务阻塞指定的秒数，用于在真实的redis上测试超时
+func debugSleep(c *Cacher, seconds float64) error {
+	_, err := c.Do("DEBUG", "SLEEP", seconds)
+	return err
+}
+
+// faultConn 包装一个redis连接，在执行命令前模拟延迟，并可以注入错误
+type faultConn struct {
+	redis.Conn
+	delay time.Duration
+	err   error
+}
+
+func (fc *faultConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	time.Sleep(fc.delay)
+	if fc.err != nil {
+		return nil, fc.err
+	}
+	return fc.Conn.Do(commandName, args...)
+}
+
+func (fc *faultConn) Send(commandName string, args ...interface{}) error {
+	if fc.err != nil {
+		return fc.err
+	}
+	return fc.Conn.Send(commandName, args...)
+}
+
+func (fc *faultConn) Receive() (interface{}, error) {
+	time.Sleep(fc.delay)
+	if fc.err != nil {
+		return nil, fc.err
+	}
+	return fc.Conn.Receive()
+}
+
+func (fc *faultConn) Flush() error {
+	if fc.err != nil {
+		return fc.err
+	}
+	return fc.Conn.Flush()
+}
+
+func (fc *faultConn) Err() error {
+	if fc.err != nil {
+		return fc.err
+	}
+	return fc.Conn.Err()
+}
+
+func (fc *faultConn) Close() error {
+	if fc.Conn == nil {
+		return nil
+	}
+	return fc.Conn.Close()
+}
+
+// getFaultCacher 返回一个所有连接都会延迟delay，并且在err不为nil时所有命令都返回err的Cacher。
+// err不为nil时不会连接redis服务。
+func getFaultCacher(delay time.Duration, err error) *Cacher {
+	c := getCacher()
+	c.conns.state.pool = &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			if err != nil {
+				return &faultConn{delay: delay, err: err}, nil
+			}
+			conn, dialErr := redis.Dial("tcp", testServer.Addr())
+			if dialErr != nil {
+				return nil, dialErr
+			}
+			return &faultConn{Conn: conn, delay: delay}, nil
+		},
+	}
+	return c
+}
+
 func TestGetSet(t *testing.T) {
 	var err error
 	c := getCacher()
@@ -81,6 +229,24 @@ func TestGetSet(t *testing.T) {
 	Equal(t, 23, valUser.Age)
 }
 
+func TestGetObjects(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("user:3")
+	err = c.Set("user:1", &User{Name: "corel", Age: 23}, 30)
+	NoError(t, err)
+	err = c.Set("user:2", &User{Name: "zen", Age: 18}, 30)
+	NoError(t, err)
+	users, err := c.GetObjects([]string{"user:1", "user:2", "user:3"}, func(key string) interface{} {
+		return &User{}
+	})
+	NoError(t, err)
+	Equal(t, map[string]interface{}{
+		"user:1": &User{Name: "corel", Age: 23},
+		"user:2": &User{Name: "zen", Age: 18},
+	}, users)
+}
+
 func TestIncrDecr(t *testing.T) {
 	var err error
 	c := getCacher()
@@ -108,12 +274,172 @@ func TestExpire(t *testing.T) {
 	err = c.Set("name", "corel", 1)
 	NoError(t, err)
 
-	time.Sleep(2 * time.Second)
+	testServer.FastForward(2 * time.Second)
 
 	_, err = c.GetString("name")
 	Error(t, err)
 }
 
+func TestGetEx(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.Set("name", "corel", 10)
+	NoError(t, err)
+	name, err := c.GetExString("name", 100)
+	NoError(t, err)
+	Equal(t, "corel", name)
+	ttl, err := c.TTL("name")
+	NoError(t, err)
+	Equal(t, int64(100), ttl)
+
+	_, err = c.GetPersist("name")
+	NoError(t, err)
+	ttl, err = c.TTL("name")
+	NoError(t, err)
+	Equal(t, int64(-1), ttl)
+}
+
+func TestDelIfEquals(t *testing.T) {
+	var err error
+	c := getCacher()
+	user := User{Name: "corel", Age: 23}
+	err = c.Set("cached_user", user, 10)
+	NoError(t, err)
+	deleted, err := c.DelIfEquals("cached_user", User{Name: "corel", Age: 18})
+	NoError(t, err)
+	Equal(t, false, deleted)
+	deleted, err = c.DelIfEquals("cached_user", user)
+	NoError(t, err)
+	Equal(t, true, deleted)
+	exists, err := c.Exists("cached_user")
+	NoError(t, err)
+	Equal(t, false, exists)
+	_, err = c.DelIfEquals("", user)
+	Equal(t, ErrEmptyKey, err)
+}
+
+func TestGetObjectTTL(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.Set("ttl_user", User{Name: "corel", Age: 23}, 30)
+	NoError(t, err)
+	var user User
+	ttl, err := c.GetObjectTTL("ttl_user", &user)
+	NoError(t, err)
+	Equal(t, int64(30), ttl)
+	Equal(t, User{Name: "corel", Age: 23}, user)
+
+	err = c.Set("ttl_user", User{Name: "corel", Age: 24}, 0)
+	NoError(t, err)
+	ttl, err = c.GetObjectTTL("ttl_user", &user)
+	NoError(t, err)
+	Equal(t, int64(-1), ttl)
+	Equal(t, 24, user.Age)
+
+	c.Del("ttl_user")
+	ttl, err = c.GetObjectTTL("ttl_user", &user)
+	Equal(t, ErrNil, err)
+	Equal(t, int64(-2), ttl)
+}
+
+func TestExpireMany(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("missing")
+	err = c.Set("name", "corel", 0)
+	NoError(t, err)
+	err = c.Set("age", 23, 0)
+	NoError(t, err)
+	result, err := c.ExpireMany(20, "name", "age", "missing")
+	NoError(t, err)
+	Equal(t, map[string]bool{"name": true, "age": true, "missing": false}, result)
+	ttl, err := c.TTL("age")
+	NoError(t, err)
+	Equal(t, int64(20), ttl)
+}
+
+func TestScanPage(t *testing.T) {
+	c := getCacher()
+	want := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("scan_page_%d", i)
+		err := c.Set(key, i, 30)
+		NoError(t, err)
+		want[key] = true
+	}
+	got := map[string]bool{}
+	var cursor uint64
+	for {
+		keys, next, err := c.ScanPage(cursor, "scan_page_*", 2)
+		NoError(t, err)
+		for _, key := range keys {
+			got[key] = true
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	Equal(t, want, got)
+}
+
+func TestFindBigKeys(t *testing.T) {
+	c := getCacher()
+	err := c.Set("bigkeys_small", "a", 30)
+	NoError(t, err)
+	err = c.Set("bigkeys_large", strings.Repeat("a", 4096), 30)
+	NoError(t, err)
+	_, err = c.HSet("bigkeys_hash", "name", "corel")
+	NoError(t, err)
+	infos, err := c.FindBigKeys("bigkeys_*", 10, 0)
+	NoError(t, err)
+	Equal(t, 3, len(infos))
+	Equal(t, "bigkeys_large", infos[0].Key)
+	Equal(t, "string", infos[0].Type)
+	Equal(t, true, infos[0].Size >= 4096)
+
+	infos, err = c.FindBigKeys("bigkeys_*", 1, 2)
+	NoError(t, err)
+	Equal(t, 2, len(infos))
+}
+
+func TestSlidingExpire(t *testing.T) {
+	// 6.2.0以上使用 GETEX ，以下使用管道执行 GET 和 EXPIRE
+	for _, version := range []string{"6.2.0", "6.0.0"} {
+		c := getVersionCacher(t, Options{Prefix: "zengate_", SlidingExpire: 100}, version)
+		err := c.Set("session", "corel", 10)
+		NoError(t, err)
+		err = c.Expire("session", 10)
+		NoError(t, err)
+		session, err := c.GetString("session")
+		NoError(t, err)
+		Equal(t, "corel", session)
+		ttl, err := c.TTL("session")
+		NoError(t, err)
+		Equal(t, int64(100), ttl)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("alive")
+	err = c.Heartbeat("alive", "node-1", 10)
+	NoError(t, err)
+	err = c.Expire("alive", 3)
+	NoError(t, err)
+	err = c.Heartbeat("alive", "node-1", 10)
+	NoError(t, err)
+	ttl, err := c.TTL("alive")
+	NoError(t, err)
+	Equal(t, int64(10), ttl)
+	err = c.Heartbeat("alive", "node-2", 20)
+	NoError(t, err)
+	val, err := c.GetString("alive")
+	NoError(t, err)
+	Equal(t, "node-2", val)
+}
+
 func TestHash(t *testing.T) {
 	var err error
 	c := getCacher()
@@ -128,6 +454,168 @@ func TestHash(t *testing.T) {
 	Equal(t, m["age"], age)
 }
 
+func TestHRandField(t *testing.T) {
+	c := getCacher()
+	c.Del("options")
+	options := map[string]string{"a": "red", "b": "green", "c": "blue"}
+	for field, value := range options {
+		_, err := c.HSet("options", field, value)
+		NoError(t, err)
+	}
+
+	// count 为正数时返回不重复的字段，数量不超过字段数
+	fields, err := c.HRandField("options", 5)
+	NoError(t, err)
+	sort.Strings(fields)
+	Equal(t, []string{"a", "b", "c"}, fields)
+	fields, err = c.HRandField("options", 2)
+	NoError(t, err)
+	Equal(t, 2, len(fields))
+	if len(fields) == 2 && fields[0] == fields[1] {
+		t.Errorf("expected distinct fields, got %v", fields)
+	}
+
+	// count 为负数时返回指定数量的字段，字段可能重复
+	fields, err = c.HRandField("options", -10)
+	NoError(t, err)
+	Equal(t, 10, len(fields))
+	for _, field := range fields {
+		if _, ok := options[field]; !ok {
+			t.Errorf("unexpected field %s", field)
+		}
+	}
+
+	values, err := c.HRandFieldWithValues("options", 3)
+	NoError(t, err)
+	Equal(t, options, values)
+
+	c = getVersionCacher(t, Options{}, "6.0.16")
+	_, err = c.HRandField("options", 1)
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+	_, err = c.HRandFieldWithValues("options", 1)
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestSRandMember(t *testing.T) {
+	c := getCacher()
+	c.Del("featured")
+	_, err := c.Do("SADD", "zengate_featured", "a", "b", "c")
+	NoError(t, err)
+
+	members, err := c.SRandMember("featured", 5)
+	NoError(t, err)
+	sort.Strings(members)
+	Equal(t, []string{"a", "b", "c"}, members)
+	members, err = c.SRandMember("featured", 2)
+	NoError(t, err)
+	Equal(t, 2, len(members))
+	if len(members) == 2 && members[0] == members[1] {
+		t.Errorf("expected distinct members, got %v", members)
+	}
+	members, err = c.SRandMember("featured", -6)
+	NoError(t, err)
+	Equal(t, 6, len(members))
+
+	// 不会移除返回的成员
+	n, err := Int64(c.Do("SCARD", "zengate_featured"))
+	NoError(t, err)
+	Equal(t, int64(3), n)
+	members, err = c.SRandMember("missing", 3)
+	NoError(t, err)
+	Equal(t, 0, len(members))
+}
+
+func TestSInterCard(t *testing.T) {
+	c := getCacher()
+	c.Del("audience1")
+	c.Del("audience2")
+	_, err := c.Do("SADD", "zengate_audience1", "a", "b", "c", "d")
+	NoError(t, err)
+	_, err = c.Do("SADD", "zengate_audience2", "b", "c", "d", "e")
+	NoError(t, err)
+
+	n, err := c.SInterCard(0, "audience1", "audience2")
+	NoError(t, err)
+	Equal(t, int64(3), n)
+	// 达到 limit 后停止计算
+	n, err = c.SInterCard(2, "audience1", "audience2")
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	n, err = c.SInterCard(0, "audience1", "missing")
+	NoError(t, err)
+	Equal(t, int64(0), n)
+
+	_, err = c.SInterCard(0, "audience1", "")
+	Equal(t, ErrEmptyKey, err)
+	c = getVersionCacher(t, Options{}, "6.2.14")
+	_, err = c.SInterCard(0, "audience1", "audience2")
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestHMSetMany(t *testing.T) {
+	var err error
+	c := getCacher()
+	items := map[string]interface{}{
+		"product:1": map[string]interface{}{"name": "apple", "price": 5},
+		"product:2": map[string]interface{}{"name": "pear", "price": 3},
+	}
+	err = c.HMSetMany(items, 10)
+	NoError(t, err)
+
+	price, err := c.HGetInt("product:2", "price")
+	NoError(t, err)
+	Equal(t, 3, price)
+	ttl, err := c.TTL("product:1")
+	NoError(t, err)
+	Equal(t, int64(10), ttl)
+}
+
+func TestDoMany(t *testing.T) {
+	c := getCacher()
+	c.Del("visits")
+	c.Del("hlist")
+	c.RPush("hlist", "name")
+	replies, err := c.DoMany([][]interface{}{
+		{"INCR", "zengate_visits"},
+		{"INCRBY", "zengate_visits", 2},
+		{"GET", "zengate_hlist"},
+		{"GET", "zengate_visits"},
+	})
+	Equal(t, true, IsWrongType(err))
+	Equal(t, 4, len(replies))
+	Equal(t, int64(1), replies[0])
+	Equal(t, int64(3), replies[1])
+	Equal(t, true, IsWrongType(replies[2].(error)))
+	Equal(t, []byte("3"), replies[3])
+
+	replies, err = c.DoMany(nil)
+	NoError(t, err)
+	Equal(t, 0, len(replies))
+	_, err = c.DoMany([][]interface{}{{"PING"}, {}})
+	Error(t, err)
+}
+
+func TestDoScanStruct(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.HMSet("huser", map[string]interface{}{"name": "corel", "age": 23}, 10)
+	NoError(t, err)
+
+	var user struct {
+		Name string `redis:"name"`
+		Age  int    `redis:"age"`
+	}
+	err = c.DoScanStruct(&user, "HGETALL", "zengate_huser")
+	NoError(t, err)
+	Equal(t, "corel", user.Name)
+	Equal(t, 23, user.Age)
+
+	c.Del("hlist")
+	c.RPush("hlist", "name")
+	err = c.DoScanStruct(&user, "LRANGE", "zengate_hlist", 0, -1)
+	Error(t, err)
+}
+
 func TestSortedSet(t *testing.T) {
 	var err error
 	c := getCacher()
@@ -139,3 +627,1849 @@ func TestSortedSet(t *testing.T) {
 	NoError(t, err)
 	Equal(t, int64(82), score)
 }
+
+func TestZAddMany(t *testing.T) {
+	c := getCacher()
+	c.Del("board")
+	n, err := c.ZAddMany("board", []ZMember{{"corel", 82.5}, {"zen", 86}})
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	n, err = c.ZAddMany("board", []ZMember{{"corel", 90}, {"lee", 70}})
+	NoError(t, err)
+	Equal(t, int64(1), n)
+	score, err := c.ZScore("board", "corel")
+	NoError(t, err)
+	Equal(t, int64(90), score)
+}
+
+func TestZMScore(t *testing.T) {
+	c := getCacher()
+	c.Del("board")
+	_, err := c.ZAddMany("board", []ZMember{{"corel", 82.5}, {"zen", 86}})
+	NoError(t, err)
+	scores, err := c.ZMScore("board", "zen", "missing", "corel")
+	NoError(t, err)
+	corel, zen := 82.5, 86.0
+	Equal(t, []*float64{&zen, nil, &corel}, scores)
+}
+
+func TestZRangeByScoreOpts(t *testing.T) {
+	c := getCacher()
+	c.Del("board")
+	_, err := c.ZAddMany("board", []ZMember{{"corel", 82.5}, {"zen", 86}, {"gate", 90}, {"last", 60}})
+	NoError(t, err)
+
+	members, err := c.ZRangeByScoreOpts("board", ZRangeOpts{Min: "(60", WithScores: true})
+	NoError(t, err)
+	Equal(t, []ZMember{{"corel", 82.5}, {"zen", 86}, {"gate", 90}}, members)
+
+	members, err = c.ZRangeByScoreOpts("board", ZRangeOpts{Max: "(90", Offset: 1, Count: 2})
+	NoError(t, err)
+	Equal(t, []ZMember{{Member: "corel"}, {Member: "zen"}}, members)
+
+	members, err = c.ZRangeByScoreOpts("board", ZRangeOpts{Min: "82.5", Max: "82.5", WithScores: true})
+	NoError(t, err)
+	Equal(t, []ZMember{{"corel", 82.5}}, members)
+
+	_, err = c.ZRangeByScoreOpts("", ZRangeOpts{})
+	Equal(t, ErrEmptyKey, err)
+}
+
+func TestFeed(t *testing.T) {
+	c := getCacher()
+	c.Del("feed")
+	NoError(t, c.FeedPush("feed", User{Name: "zen", Age: 30}, 1700000002))
+	NoError(t, c.FeedPush("feed", User{Name: "corel", Age: 23}, 1700000001))
+	NoError(t, c.FeedPush("feed", User{Name: "gate", Age: 18}, 1700000003))
+
+	var users []User
+	scores, err := c.FeedRange("feed", 0, 1, &users)
+	NoError(t, err)
+	Equal(t, []User{{Name: "corel", Age: 23}, {Name: "zen", Age: 30}}, users)
+	Equal(t, []float64{1700000001, 1700000002}, scores)
+
+	scores, err = c.FeedRange("feed", 5, 10, &users)
+	NoError(t, err)
+	Equal(t, 0, len(users))
+	Equal(t, 0, len(scores))
+}
+
+func TestZStore(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("week1")
+	c.Del("week2")
+	_, err = c.ZAdd("week1", 10, "corel")
+	NoError(t, err)
+	_, err = c.ZAdd("week1", 5, "zen")
+	NoError(t, err)
+	_, err = c.ZAdd("week2", 20, "corel")
+	NoError(t, err)
+
+	n, err := c.ZUnionStore("total", []string{"week1", "week2"}, []float64{1, 2}, "SUM")
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	score, err := c.ZScore("total", "corel")
+	NoError(t, err)
+	Equal(t, int64(50), score)
+
+	n, err = c.ZInterStore("both", []string{"week1", "week2"}, nil, "MAX")
+	NoError(t, err)
+	Equal(t, int64(1), n)
+	score, err = c.ZScore("both", "corel")
+	NoError(t, err)
+	Equal(t, int64(20), score)
+}
+
+func TestZRangeStore(t *testing.T) {
+	// miniredis没有实现 ZRANGESTORE ，这里记录命令的参数并返回储存的成员数量
+	var commands [][]string
+	version := "6.2.0"
+	options := Options{Prefix: "app_"}
+	c, s := getHookedCacher(t, options, func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool {
+		if writeServerInfo(p, cmd, version) {
+			return true
+		}
+		if cmd != "ZRANGESTORE" {
+			return false
+		}
+		commands = append(commands, args)
+		p.WriteInt(3)
+		return true
+	})
+
+	n, err := c.ZRangeStore("top", "board", 0, 2, false, false)
+	NoError(t, err)
+	Equal(t, int64(3), n)
+	_, err = c.ZRangeStore("top", "board", 100, 50, true, true)
+	NoError(t, err)
+	Equal(t, [][]string{
+		{"app_top", "app_board", "0", "2"},
+		{"app_top", "app_board", "100", "50", "BYSCORE", "REV"},
+	}, commands)
+
+	_, err = c.ZRangeStore("", "board", 0, 2, false, false)
+	Equal(t, ErrEmptyKey, err)
+	version = "6.0.16"
+	options.Addr = s.Addr()
+	options.DisableSignalClose = true
+	NoError(t, c.Reset(options))
+	_, err = c.ZRangeStore("top", "board", 0, 2, false, false)
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+	Equal(t, 2, len(commands))
+}
+
+func TestGeoRadiusStore(t *testing.T) {
+	c := getCacher()
+	c.Del("shops")
+	c.Del("nearby")
+	c.Del("nearby_dist")
+	NoError(t, c.GeoAdd("shops", 116.397128, 39.916527, "tiananmen"))
+	NoError(t, c.GeoAdd("shops", 116.403414, 39.924091, "jingshan"))
+	NoError(t, c.GeoAdd("shops", 121.473701, 31.230416, "shanghai"))
+
+	n, err := c.GeoRadiusStore("shops", 116.4, 39.92, 5, "km", GeoOptions{Store: "nearby"})
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	members, err := redis.Strings(c.Do("ZRANGE", "zengate_nearby", 0, -1))
+	NoError(t, err)
+	sort.Strings(members)
+	Equal(t, []string{"jingshan", "tiananmen"}, members)
+
+	// STOREDIST 的分数为与中心的距离
+	n, err = c.GeoRadiusByMemberStore("shops", "tiananmen", 5, "km", GeoOptions{StoreDist: "nearby_dist", Order: "ASC"})
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	dist, err := redis.Float64(c.Do("ZSCORE", "zengate_nearby_dist", "tiananmen"))
+	NoError(t, err)
+	Equal(t, float64(0), dist)
+	dist, err = redis.Float64(c.Do("ZSCORE", "zengate_nearby_dist", "jingshan"))
+	NoError(t, err)
+	if dist <= 0 || dist >= 5 {
+		t.Errorf("expected distance in (0, 5) km, got %v", dist)
+	}
+
+	_, err = c.GeoRadiusStore("shops", 116.4, 39.92, 5, "km", GeoOptions{})
+	Error(t, err)
+}
+
+func TestZDiff(t *testing.T) {
+	c := getCacher()
+	_, err := c.ZDiff(nil, false)
+	Equal(t, ErrEmptyKey, err)
+	_, err = c.ZDiffStore("", []string{"week1"})
+	Equal(t, ErrEmptyKey, err)
+
+	members, err := parseZMembers([]interface{}{[]byte("corel"), []byte("82.5"), []byte("zen"), []byte("86")}, true)
+	NoError(t, err)
+	Equal(t, []ZMember{{"corel", 82.5}, {"zen", 86}}, members)
+	members, err = parseZMembers([]interface{}{[]byte("corel")}, false)
+	NoError(t, err)
+	Equal(t, []ZMember{{Member: "corel"}}, members)
+	_, err = parseZMembers([]interface{}{[]byte("corel")}, true)
+	Equal(t, true, errors.Is(err, ErrUnexpectedReply))
+
+	c = getVersionCacher(t, Options{}, "6.0.9")
+	_, err = c.ZDiff([]string{"week1", "week2"}, true)
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+	_, err = c.ZDiffStore("lost", []string{"week1", "week2"})
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestFaultInjection(t *testing.T) {
+	injected := errors.New("injected failure")
+	c := getFaultCacher(100*time.Millisecond, injected)
+	start := time.Now()
+	_, err := c.GetString("name")
+	Equal(t, true, errors.Is(err, injected))
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("expected latency of at least 100ms, got %s", d)
+	}
+}
+
+func TestObjectFreq(t *testing.T) {
+	// miniredis没有实现 OBJECT FREQ ，这里模拟 maxmemory-policy 为LFU策略和不是LFU策略时的回复
+	lfu := true
+	c, _ := getHookedCacher(t, Options{Prefix: "app_"}, func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool {
+		if cmd != "OBJECT" || len(args) < 2 || !strings.EqualFold(args[0], "FREQ") {
+			return false
+		}
+		switch {
+		case !lfu:
+			p.WriteError("ERR An LFU maxmemory policy is not selected, access frequency not tracked.")
+		case args[1] == "app_name":
+			p.WriteInt(7)
+		default:
+			p.WriteNull()
+		}
+		return true
+	})
+
+	freq, err := c.ObjectFreq("name")
+	NoError(t, err)
+	Equal(t, int64(7), freq)
+	_, err = c.ObjectFreq("missing")
+	Equal(t, redis.ErrNil, err)
+
+	lfu = false
+	_, err = c.ObjectFreq("name")
+	Error(t, err)
+	if err != nil && !strings.Contains(err.Error(), "requires an LFU maxmemory-policy") {
+		t.Errorf("expected LFU policy error, got %v", err)
+	}
+	var e redis.Error
+	Equal(t, true, errors.As(err, &e))
+	_, err = c.ObjectFreq("")
+	Equal(t, ErrEmptyKey, err)
+}
+
+func TestDebugObject(t *testing.T) {
+	// miniredis没有实现 DEBUG OBJECT ，这里返回redis输出格式的状态字符串
+	c, _ := getHookedCacher(t, Options{Prefix: "app_"}, func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool {
+		if cmd != "DEBUG" || len(args) < 2 || !strings.EqualFold(args[0], "OBJECT") {
+			return false
+		}
+		if !s.Exists(args[1]) {
+			p.WriteError("ERR no such key")
+			return true
+		}
+		p.WriteInline("Value at:0x7f2b8c00e0c0 refcount:1 encoding:quicklist serializedlength:18 lru:1 lru_seconds_idle:3 ql_nodes:1")
+		return true
+	})
+	NoError(t, c.RPush("events", "login"))
+
+	info, err := c.DebugObject("events")
+	NoError(t, err)
+	Equal(t, "quicklist", info["encoding"])
+	Equal(t, "18", info["serializedlength"])
+	Equal(t, "1", info["ql_nodes"])
+	Equal(t, "0x7f2b8c00e0c0", info["at"])
+	_, err = c.DebugObject("missing")
+	Error(t, err)
+	_, err = c.DebugObject("")
+	Equal(t, ErrEmptyKey, err)
+}
+
+func TestDebugSleep(t *testing.T) {
+	c := getCacher()
+	start := time.Now()
+	if err := debugSleep(c, 0.2); err != nil {
+		t.Skip("DEBUG SLEEP is not available:", err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("expected latency of at least 200ms, got %s", d)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("missing")
+	_, err = c.GetString("missing")
+	Equal(t, true, errors.Is(err, ErrNil))
+	Equal(t, true, errors.Is(err, redis.ErrNil))
+
+	err = c.Set("name", "corel", 10)
+	NoError(t, err)
+	_, err = c.HGet("name", "field")
+	var redisErr redis.Error
+	Equal(t, true, errors.As(err, &redisErr))
+	Equal(t, true, IsWrongType(err))
+	Equal(t, false, IsWrongType(ErrNil))
+
+	err = c.StartAndGC("options")
+	Equal(t, true, errors.Is(err, ErrUnsupportedOptions))
+}
+
+func TestConfigUnavailable(t *testing.T) {
+	c := getCacher()
+	// miniredis没有实现 CONFIG 命令，与被禁用的情况相同
+	_, err := c.ConfigGet("maxmemory*")
+	Equal(t, true, strings.Contains(err.Error(), "CONFIG is disabled or renamed"))
+	var redisErr redis.Error
+	Equal(t, true, errors.As(err, &redisErr))
+	err = c.ConfigSet("maxmemory", "100mb")
+	Equal(t, true, strings.Contains(err.Error(), "CONFIG is disabled or renamed"))
+}
+
+func TestCommandAliases(t *testing.T) {
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "zengate_", CommandAliases: map[string]string{"flushdb": "f1u5hdb"}})
+	NoError(t, err)
+	err = c.Set("name", "corel", 10)
+	NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	name, err := String(c.DoContext(ctx, "GET", "zengate_name"))
+	NoError(t, err)
+	Equal(t, "corel", name)
+	// miniredis不支持重命名命令，这里通过错误信息确认发送的是重命名后的命令
+	err = c.Flush()
+	Equal(t, true, strings.Contains(err.Error(), "f1u5hdb"))
+}
+
+func TestClose(t *testing.T) {
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "zengate_", DisableSignalClose: true})
+	NoError(t, err)
+	err = c.Close()
+	NoError(t, err)
+	_, err = c.GetString("name")
+	Equal(t, true, errors.Is(err, ErrClosed))
+}
+
+func TestPrefixSeparator(t *testing.T) {
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "app", PrefixSeparator: ":", DisableSignalClose: true})
+	NoError(t, err)
+	err = c.Set("user", "corel", 10)
+	NoError(t, err)
+	Equal(t, true, testServer.Exists("app:user"))
+	Equal(t, "other:user", c.WithPrefix("other").getKey("user"))
+	Equal(t, "user", c.NoPrefix().getKey("user"))
+	keys, _, err := c.ScanPage(0, "user", 100)
+	NoError(t, err)
+	Equal(t, []string{"user"}, keys)
+
+	_, err = New(Options{Addr: testServer.Addr(), Prefix: "app*", DisableSignalClose: true})
+	Error(t, err)
+}
+
+func TestEmptyKey(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.Set("", "corel", 10)
+	Equal(t, ErrEmptyKey, err)
+	_, err = c.GetString("")
+	Equal(t, ErrEmptyKey, err)
+	err = c.Del("")
+	Equal(t, ErrEmptyKey, err)
+	_, err = c.HGet("", "field")
+	Equal(t, ErrEmptyKey, err)
+	err = c.HMSetMany(map[string]interface{}{"": map[string]interface{}{"name": "corel"}}, 0)
+	Equal(t, ErrEmptyKey, err)
+}
+
+func TestNewConnectError(t *testing.T) {
+	s, err := miniredis.Run()
+	NoError(t, err)
+	addr := s.Addr()
+	s.Close()
+	_, err = New(Options{Addr: addr})
+	Error(t, err)
+
+	s, err = miniredis.Run()
+	NoError(t, err)
+	defer s.Close()
+	s.RequireAuth("secret")
+	_, err = New(Options{Addr: s.Addr(), Password: "wrong"})
+	Error(t, err)
+}
+
+func TestReset(t *testing.T) {
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "zengate_", DisableSignalClose: true})
+	NoError(t, err)
+	defer c.Close()
+	other := c.WithPrefix("other_")
+	oldPool := c.state().pool
+
+	s := miniredis.RunT(t)
+	s.RequireAuth("rotated")
+	err = c.Reset(Options{Addr: s.Addr(), Password: "wrong"})
+	Error(t, err)
+	Equal(t, oldPool, c.state().pool)
+
+	err = c.Reset(Options{Addr: s.Addr(), Password: "rotated"})
+	NoError(t, err)
+	err = c.Set("name", "corel", 10)
+	NoError(t, err)
+	Equal(t, true, s.Exists("zengate_name"))
+	err = other.Set("name", "corel", 10)
+	NoError(t, err)
+	Equal(t, true, s.Exists("other_name"))
+	_, err = oldPool.Get().Do("PING")
+	Error(t, err)
+}
+
+// TestResetConcurrent 在执行命令的同时不断替换连接池，需要使用 go test -race 运行才能发现数据竞争
+func TestResetConcurrent(t *testing.T) {
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "zengate_", DisableSignalClose: true})
+	NoError(t, err)
+	defer c.Close()
+
+	done := make(chan struct{})
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			key := fmt.Sprintf("reset_%d", i)
+			for {
+				select {
+				case <-done:
+					errs <- nil
+					return
+				default:
+				}
+				if err := c.Set(key, i, 10); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := c.WithPrefix("zengate_").GetInt(key); err != nil {
+					errs <- err
+					return
+				}
+				c.Supports("GETEX")
+			}
+		}(i)
+	}
+	for i := 0; i < 20; i++ {
+		err = c.Reset(Options{Addr: testServer.Addr(), Wait: true, MaxActive: 4})
+		NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(done)
+	for i := 0; i < 8; i++ {
+		NoError(t, <-errs)
+	}
+}
+
+func TestACLAuth(t *testing.T) {
+	s := miniredis.RunT(t)
+	s.RequireUserAuth("reader", "secret")
+	_, err := New(Options{Addr: s.Addr(), Password: "secret", DisableSignalClose: true})
+	Error(t, err)
+	c, err := New(Options{Addr: s.Addr(), Username: "reader", Password: "secret", DisableSignalClose: true})
+	NoError(t, err)
+	defer c.Close()
+	err = c.Set("name", "corel", 10)
+	NoError(t, err)
+}
+
+func TestEvalObject(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.Set("user", &User{Name: "corel", Age: 23}, 30)
+	NoError(t, err)
+	script := redis.NewScript(1, `
+local user = cjson.decode(redis.call("GET", KEYS[1]))
+user["Age"] = user["Age"] + tonumber(ARGV[1])
+local data = cjson.encode(user)
+redis.call("SET", KEYS[1], data)
+return data`)
+	user := &User{}
+	err = c.EvalObject(script, []string{"user"}, user, 1)
+	NoError(t, err)
+	Equal(t, "corel", user.Name)
+	Equal(t, 24, user.Age)
+}
+
+func TestClientName(t *testing.T) {
+	c, err := New(Options{Addr: testServer.Addr(), ClientName: "redisgo-test"})
+	NoError(t, err)
+	name, err := String(c.Do("CLIENT", "GETNAME"))
+	NoError(t, err)
+	Equal(t, "redisgo-test", name)
+}
+
+func TestNoPrefix(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.NoPrefix().Set("other_name", "zen", 10)
+	NoError(t, err)
+	name, err := c.WithPrefix("other_").GetString("name")
+	NoError(t, err)
+	Equal(t, "zen", name)
+	_, err = c.GetString("name2")
+	Error(t, err)
+}
+
+func TestFormatTag(t *testing.T) {
+	var err error
+	c := getCacher()
+	user := &User{Name: "corel", Age: 23}
+	err = c.Set("legacy_user", user, 30)
+	NoError(t, err)
+
+	jsonCacher, err := New(Options{
+		Addr:      testServer.Addr(),
+		Prefix:    "zengate_",
+		FormatTag: 1,
+	})
+	NoError(t, err)
+	err = jsonCacher.Set("json_user", user, 30)
+	NoError(t, err)
+
+	gobMarshal := func(v interface{}) ([]byte, error) {
+		var buf bytes.Buffer
+		err := gob.NewEncoder(&buf).Encode(v)
+		return buf.Bytes(), err
+	}
+	gobUnmarshal := func(data []byte, v interface{}) error {
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+	}
+	gobCacher, err := New(Options{
+		Addr:      testServer.Addr(),
+		Prefix:    "zengate_",
+		Marshal:   gobMarshal,
+		Unmarshal: gobUnmarshal,
+		FormatTag: 2,
+		Unmarshalers: map[byte]func(data []byte, v interface{}) error{
+			0: json.Unmarshal,
+			1: json.Unmarshal,
+		},
+	})
+	NoError(t, err)
+	err = gobCacher.Set("gob_user", user, 30)
+	NoError(t, err)
+
+	for _, key := range []string{"legacy_user", "json_user", "gob_user"} {
+		val := &User{}
+		err = gobCacher.GetObject(key, val)
+		NoError(t, err)
+		Equal(t, user, val)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	var err error
+	c, err := New(Options{
+		Addr:             testServer.Addr(),
+		Prefix:           "zengate_",
+		Compress:         true,
+		CompressMinBytes: 100,
+	})
+	NoError(t, err)
+	small := &User{Name: "corel", Age: 23}
+	err = c.Set("small_user", small, 30)
+	NoError(t, err)
+	large := &User{Name: strings.Repeat("corel", 100), Age: 23}
+	err = c.Set("large_user", large, 30)
+	NoError(t, err)
+
+	raw, err := testServer.Get("zengate_large_user")
+	NoError(t, err)
+	if len(raw) >= len(large.Name) {
+		t.Errorf("expected compressed value, got %d bytes", len(raw))
+	}
+
+	// 关闭压缩后仍然可以读取压缩过的数据
+	plain := getCacher()
+	for key, user := range map[string]*User{"small_user": small, "large_user": large} {
+		val := &User{}
+		err = c.GetObject(key, val)
+		NoError(t, err)
+		Equal(t, user, val)
+		val = &User{}
+		err = plain.GetObject(key, val)
+		NoError(t, err)
+		Equal(t, user, val)
+	}
+}
+
+func TestDecodeTrailingData(t *testing.T) {
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "zengate_", Compress: true, CompressMinBytes: 1})
+	NoError(t, err)
+	// 与 json.Unmarshal 一致，值后面的空白字符可以忽略，其他数据返回错误
+	for value, valid := range map[string]bool{
+		`{"Name":"corel","Age":23}`:        true,
+		`{"Name":"corel","Age":23}` + "\n": true,
+		`{"Name":"corel","Age":23} x`:      false,
+		`{"Name":"corel","Age":23}{}`:      false,
+		`{"Name":"corel","Age":23} 1`:      false,
+	} {
+		compressed, err := gzipCompress([]byte(value))
+		NoError(t, err)
+		for _, data := range []string{value, string(compressed)} {
+			testServer.Set("zengate_trailing_user", data)
+			var user User
+			err = c.GetObject("trailing_user", &user)
+			Equal(t, valid, err == nil)
+			Equal(t, valid, json.Unmarshal([]byte(value), &user) == nil)
+		}
+	}
+	// 出错的Decoder不会放回池中，之后的读取不受影响
+	NoError(t, c.Set("trailing_user", User{Name: "corel", Age: 23}, 30))
+	var user User
+	NoError(t, c.GetObject("trailing_user", &user))
+	Equal(t, User{Name: "corel", Age: 23}, user)
+}
+
+func TestCompressCustomCodec(t *testing.T) {
+	// 自定义的反序列化方法保留了传入的数据，之后的解压不能覆盖这些数据
+	var retained [][]byte
+	retain := func(data []byte, v interface{}) error {
+		retained = append(retained, data)
+		return json.Unmarshal(data, v)
+	}
+	c, err := New(Options{
+		Addr:               testServer.Addr(),
+		Prefix:             "zengate_",
+		Compress:           true,
+		CompressMinBytes:   1,
+		Unmarshal:          retain,
+		DisableSignalClose: true,
+	})
+	NoError(t, err)
+	defer c.Close()
+	first := &User{Name: strings.Repeat("corel", 20), Age: 23}
+	second := &User{Name: strings.Repeat("zen", 50), Age: 18}
+	NoError(t, c.Set("codec_first", first, 30))
+	NoError(t, c.Set("codec_second", second, 30))
+	NoError(t, c.GetObject("codec_first", &User{}))
+	NoError(t, c.GetObject("codec_second", &User{}))
+	var user User
+	NoError(t, json.Unmarshal(retained[0], &user))
+	Equal(t, *first, user)
+
+	// 没有开启压缩时，恰好以gzip标记开头的其他格式的数据按原样反序列化
+	marker := func(v interface{}) ([]byte, error) {
+		return append([]byte{0x1f, 0x8b}, v.(*User).Name...), nil
+	}
+	unmarker := func(data []byte, v interface{}) error {
+		v.(*User).Name = string(data[2:])
+		return nil
+	}
+	plain, err := New(Options{Addr: testServer.Addr(), Prefix: "zengate_", Marshal: marker, Unmarshal: unmarker, DisableSignalClose: true})
+	NoError(t, err)
+	defer plain.Close()
+	NoError(t, plain.Set("codec_marker", &User{Name: "corel"}, 30))
+	user = User{}
+	NoError(t, plain.GetObject("codec_marker", &user))
+	Equal(t, "corel", user.Name)
+}
+
+type ctxKey struct{}
+
+func TestOnCommand(t *testing.T) {
+	var commands []string
+	var values []interface{}
+	c, err := New(Options{
+		Addr: testServer.Addr(),
+		OnCommand: func(ctx context.Context, commandName string, duration time.Duration, err error) {
+			commands = append(commands, commandName)
+			values = append(values, ctx.Value(ctxKey{}))
+		},
+	})
+	NoError(t, err)
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request-1")
+	_, err = c.DoContext(ctx, "SET", "name", "corel")
+	NoError(t, err)
+	_, err = c.Do("GET", "name")
+	NoError(t, err)
+	Equal(t, []string{"SET", "GET"}, commands)
+	Equal(t, []interface{}{"request-1", nil}, values)
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	trace := func(name string) func(next DoFunc) DoFunc {
+		return func(next DoFunc) DoFunc {
+			return func(commandName string, args ...interface{}) (interface{}, error) {
+				calls = append(calls, name+":"+commandName)
+				return next(commandName, args...)
+			}
+		}
+	}
+	errBlocked := errors.New("blocked")
+	block := func(next DoFunc) DoFunc {
+		return func(commandName string, args ...interface{}) (interface{}, error) {
+			if commandName == "FLUSHDB" {
+				return nil, errBlocked
+			}
+			return next(commandName, args...)
+		}
+	}
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "zengate_", Middleware: []func(next DoFunc) DoFunc{trace("outer"), trace("inner"), block}})
+	NoError(t, err)
+	err = c.Set("name", "corel", 10)
+	NoError(t, err)
+	Equal(t, []string{"outer:SETEX", "inner:SETEX"}, calls)
+	err = c.Flush()
+	Equal(t, errBlocked, err)
+}
+
+func BenchmarkPingOnBorrow(b *testing.B) {
+	for _, interval := range []time.Duration{-1, time.Minute} {
+		c, err := New(Options{Addr: testServer.Addr(), PingOnBorrowInterval: interval})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("interval=%s", interval), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Do("GET", "name"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	c := getCacher()
+	values := map[string]interface{}{
+		"scalar": 23,
+		"struct": User{Name: "corel", Age: 23},
+	}
+	for name, value := range values {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := c.Set("bench_set", value, 60); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	c := getCacher()
+	b.Run("scalar", func(b *testing.B) {
+		if err := c.Set("bench_get", 23, 60); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.GetInt("bench_get"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("struct", func(b *testing.B) {
+		if err := c.Set("bench_get", User{Name: "corel", Age: 23}, 60); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		var user User
+		for i := 0; i < b.N; i++ {
+			if err := c.GetObject("bench_get", &user); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetObject(b *testing.B) {
+	users := map[string]User{
+		"small": {Name: "corel", Age: 23},
+		"large": {Name: strings.Repeat("corel", 1000), Age: 23},
+	}
+	for _, size := range []string{"small", "large"} {
+		for _, compress := range []bool{false, true} {
+			// CompressMinBytes 为1时小对象也会压缩
+			c, err := New(Options{Addr: testServer.Addr(), Prefix: "zengate_", Compress: compress, CompressMinBytes: 1})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := c.Set("bench_user_"+size, users[size], 60); err != nil {
+				b.Fatal(err)
+			}
+			b.Run(fmt.Sprintf("size=%s/compress=%t", size, compress), func(b *testing.B) {
+				b.ReportAllocs()
+				var user User
+				for i := 0; i < b.N; i++ {
+					if err := c.GetObject("bench_user_"+size, &user); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSupports(t *testing.T) {
+	c := getVersionCacher(t, Options{}, "6.0.9")
+	Equal(t, "6.0.9", c.ServerVersion())
+	Equal(t, true, c.Supports("GET"))
+	Equal(t, false, c.Supports("getex"))
+	_, err := c.GetEx("name", 10)
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+	c = getVersionCacher(t, Options{}, "7.0.0")
+	Equal(t, true, c.Supports("GETEX"))
+	Equal(t, false, c.Supports("HEXPIRE"))
+	c = getCacher()
+	Equal(t, "", c.ServerVersion())
+	Equal(t, true, c.Supports("HEXPIRE"))
+}
+
+func TestConsumeQueues(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("high")
+	c.Del("low")
+	c.Del("processing")
+	err = c.RPush("low", "l1")
+	NoError(t, err)
+	err = c.RPush("high", "h1")
+	NoError(t, err)
+	err = c.RPush("high", "h2")
+	NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+	err = c.ConsumeQueues([]string{"high", "low"}, func(queue string, item []byte) error {
+		got = append(got, queue+":"+string(item))
+		if len(got) == 3 {
+			cancel()
+		}
+		return nil
+	}, ConsumeOptions{Context: ctx, Timeout: 10 * time.Millisecond, ProcessingQueue: "processing"})
+	NoError(t, err)
+	Equal(t, []string{"high:h1", "high:h2", "low:l1"}, got)
+	n, err := Int64(c.Do("LLEN", "zengate_processing"))
+	NoError(t, err)
+	Equal(t, int64(0), n)
+
+	c = getVersionCacher(t, Options{}, "6.0.9")
+	err = c.ConsumeQueues([]string{"high", "low"}, func(queue string, item []byte) error {
+		t.Errorf("unexpected item %s", item)
+		return nil
+	}, ConsumeOptions{Timeout: 10 * time.Millisecond, ProcessingQueue: "processing"})
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestForEachDB(t *testing.T) {
+	c := getCacher()
+	NoError(t, c.Set("name", "corel", 0))
+	testServer.DB(3).Set("zengate_name", "corel")
+	defer testServer.DB(3).Del("zengate_name")
+	var dbs []int
+	err := c.ForEachDB(func(db int, c *Cacher) error {
+		exists, err := c.Exists("name")
+		if err != nil {
+			return err
+		}
+		if exists {
+			dbs = append(dbs, db)
+		}
+		return nil
+	})
+	NoError(t, err)
+	Equal(t, []int{0, 3}, dbs)
+
+	// 数据库数量只在遍历前查询一次
+	configs := 0
+	counted, err := New(Options{
+		Addr: testServer.Addr(),
+		OnCommand: func(ctx context.Context, commandName string, duration time.Duration, err error) {
+			if commandName == "CONFIG" {
+				configs++
+			}
+		},
+		DisableSignalClose: true,
+	})
+	NoError(t, err)
+	defer counted.Close()
+	visited := 0
+	err = counted.ForEachDB(func(db int, c *Cacher) error {
+		visited++
+		return nil
+	})
+	NoError(t, err)
+	Equal(t, defaultDatabases, visited)
+	Equal(t, 1, configs)
+
+	// 连接出错时不遍历，直接返回错误
+	broken := errors.New("broken")
+	err = getFaultCacher(0, broken).ForEachDB(func(db int, c *Cacher) error {
+		t.Errorf("unexpected call for db %d", db)
+		return nil
+	})
+	Equal(t, true, errors.Is(err, broken))
+}
+
+func TestSubscribeReconnect(t *testing.T) {
+	c, s := getHookedCacher(t, Options{}, nil)
+
+	messages := make(chan string, 10)
+	reconnected := make(chan struct{}, 1)
+	err := c.SubscribeWithOptions(func(channel string, data []byte) error {
+		messages <- string(data)
+		return nil
+	}, SubscribeOptions{
+		OnReconnect: func() { reconnected <- struct{}{} },
+	}, "events")
+	NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	s.Publish("events", "before")
+	Equal(t, "before", <-messages)
+
+	s.Close()
+	NoError(t, s.Restart())
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected OnReconnect to be called")
+	}
+	s.Publish("events", "after")
+	Equal(t, "after", <-messages)
+}
+
+func TestSubscribeTyped(t *testing.T) {
+	c := getCacher()
+	users := make(chan *User, 1)
+	err := c.SubscribeTyped("typed_users", func() interface{} { return &User{} }, func(ev interface{}) error {
+		users <- ev.(*User)
+		return nil
+	})
+	NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = c.Publish("typed_users", "not json")
+	NoError(t, err)
+	data, err := json.Marshal(User{Name: "corel", Age: 23})
+	NoError(t, err)
+	_, err = c.Publish("typed_users", string(data))
+	NoError(t, err)
+	select {
+	case user := <-users:
+		Equal(t, User{Name: "corel", Age: 23}, *user)
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a decoded event")
+	}
+}
+
+func TestSubscribeGiveUp(t *testing.T) {
+	c, s := getHookedCacher(t, Options{}, nil)
+
+	gaveUp := make(chan error, 1)
+	opts := SubscribeOptions{
+		MaxResubscribeAttempts: 2,
+		OnGiveUp:               func(err error) { gaveUp <- err },
+	}
+	err := c.SubscribeWithOptions(func(channel string, data []byte) error { return nil }, opts, "events")
+	NoError(t, err)
+
+	s.Close()
+	select {
+	case err := <-gaveUp:
+		Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected OnGiveUp to be called")
+	}
+
+	// 首次订阅失败达到次数时直接返回错误
+	err = c.SubscribeWithOptions(func(channel string, data []byte) error { return nil }, opts, "events")
+	Error(t, err)
+}
+
+func TestOnPoolWait(t *testing.T) {
+	waits := make(chan time.Duration, 10)
+	c, err := New(Options{
+		Addr:       testServer.Addr(),
+		MaxActive:  1,
+		Wait:       true,
+		OnPoolWait: func(d time.Duration) { waits <- d },
+	})
+	NoError(t, err)
+	for len(waits) > 0 {
+		<-waits
+	}
+	conn := c.getConn()
+	<-waits
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		conn.Close()
+	}()
+	_, err = c.Do("PING")
+	NoError(t, err)
+	if d := <-waits; d < 100*time.Millisecond {
+		t.Errorf("expected pool wait of at least 100ms, got %s", d)
+	}
+	Equal(t, 1, c.Stats().ActiveCount)
+}
+
+func TestHExpireUnsupported(t *testing.T) {
+	c := getVersionCacher(t, Options{}, "7.2.4")
+	_, err := c.HExpire("limits", 60, "login", "sms")
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+	_, err = c.HTTL("limits", "login")
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+	_, err = c.HPersist("limits", "login")
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestGeoPositions(t *testing.T) {
+	c := getCacher()
+	c.Del("stores")
+	err := c.GeoAdd("stores", 116.397128, 39.916527, "beijing")
+	NoError(t, err)
+	points, err := c.GeoPositions("stores", "beijing", "missing")
+	NoError(t, err)
+	Equal(t, 2, len(points))
+	if math.Abs(points[0].Longitude-116.397128) > 1e-5 || math.Abs(points[0].Latitude-39.916527) > 1e-5 {
+		t.Errorf("unexpected position %+v", *points[0])
+	}
+	Equal(t, (*GeoPoint)(nil), points[1])
+}
+
+func TestGeoSearchStore(t *testing.T) {
+	args := geoSearchArgs(redis.Args{}.Add("dest", "src"), GeoSearchOptions{FromMember: "office", Radius: 5, Unit: "km", Order: "ASC", Count: 10, Any: true})
+	Equal(t, redis.Args{"dest", "src", "FROMMEMBER", "office", "BYRADIUS", float64(5), "km", "ASC", "COUNT", 10, "ANY"}, args)
+	args = geoSearchArgs(redis.Args{}, GeoSearchOptions{Longitude: 116.4, Latitude: 39.9, Width: 2, Height: 1})
+	Equal(t, redis.Args{"FROMLONLAT", 116.4, 39.9, "BYBOX", float64(2), float64(1), "m"}, args)
+
+	c := getVersionCacher(t, Options{}, "6.0.16")
+	_, err := c.GeoSearchStore("nearby", "shops", GeoSearchOptions{FromMember: "office", Radius: 5}, true)
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestParseSlowLogEntry(t *testing.T) {
+	reply := []interface{}{
+		int64(14), int64(1700000000), int64(25000),
+		[]interface{}{[]byte("KEYS"), []byte("*")},
+		[]byte("127.0.0.1:58217"), []byte("worker"),
+	}
+	entry, err := parseSlowLogEntry(reply)
+	NoError(t, err)
+	Equal(t, SlowLogEntry{
+		ID:         14,
+		Time:       time.Unix(1700000000, 0),
+		Duration:   25 * time.Millisecond,
+		Args:       []string{"KEYS", "*"},
+		ClientAddr: "127.0.0.1:58217",
+		ClientName: "worker",
+	}, entry)
+
+	// redis 4.0之前的版本没有客户端信息
+	entry, err = parseSlowLogEntry(reply[:4])
+	NoError(t, err)
+	Equal(t, "", entry.ClientAddr)
+
+	_, err = parseSlowLogEntry(reply[:3])
+	Equal(t, true, errors.Is(err, ErrUnexpectedReply))
+}
+
+func TestPushCapped(t *testing.T) {
+	c := getCacher()
+	c.Del("recent_events")
+	for i := 1; i <= 5; i++ {
+		err := c.PushCapped("recent_events", i, 3)
+		NoError(t, err)
+	}
+	events, err := redis.Ints(c.LRange("recent_events", 0, -1))
+	NoError(t, err)
+	Equal(t, []int{3, 4, 5}, events)
+	err = c.PushCapped("recent_events", 6, 0)
+	Error(t, err)
+}
+
+func TestLCSUnsupported(t *testing.T) {
+	c := getVersionCacher(t, Options{}, "6.2.14")
+	_, err := c.LCS("doc1", "doc2")
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+	_, err = c.LCSLen("doc1", "doc2")
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+	_, err = c.LCS("", "doc2")
+	Equal(t, ErrEmptyKey, err)
+}
+
+func TestParseClientInfo(t *testing.T) {
+	info := parseClientInfo("id=3 addr=127.0.0.1:52555 laddr=127.0.0.1:6379 fd=8 name=worker age=25 idle=2 flags=P db=1 sub=1 psub=0 cmd=subscribe user=default")
+	Equal(t, int64(3), info.ID)
+	Equal(t, "127.0.0.1:52555", info.Addr)
+	Equal(t, "worker", info.Name)
+	Equal(t, int64(25), info.Age)
+	Equal(t, int64(2), info.Idle)
+	Equal(t, 1, info.DB)
+	Equal(t, "P", info.Flags)
+	Equal(t, "subscribe", info.Cmd)
+	Equal(t, "1", info.Fields["sub"])
+}
+
+func TestPubSubIntrospection(t *testing.T) {
+	c := getCacher()
+	err := c.Subscribe(func(channel string, data []byte) error { return nil }, "introspect_orders")
+	NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	channels, err := c.PubSubChannels("introspect_*")
+	NoError(t, err)
+	Equal(t, []string{"introspect_orders"}, channels)
+	counts, err := c.PubSubNumSub("introspect_orders", "introspect_users")
+	NoError(t, err)
+	Equal(t, map[string]int64{"introspect_orders": 1, "introspect_users": 0}, counts)
+}
+
+func TestSize(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("size_list")
+	c.Del("size_missing")
+	err = c.Set("size_string", "corel", 10)
+	NoError(t, err)
+	err = c.RPush("size_list", 1)
+	NoError(t, err)
+	err = c.RPush("size_list", 2)
+	NoError(t, err)
+	_, err = c.HSet("size_hash", "name", "corel")
+	NoError(t, err)
+	for key, want := range map[string]int64{"size_string": 5, "size_list": 2, "size_hash": 1, "size_missing": 0} {
+		size, err := c.Size(key)
+		NoError(t, err)
+		Equal(t, want, size)
+	}
+}
+
+func TestIncrWithExpire(t *testing.T) {
+	c := getCacher()
+	c.Del("quota")
+	count, err := c.IncrWithExpire("quota", 2, time.Minute)
+	NoError(t, err)
+	Equal(t, int64(2), count)
+	err = c.Expire("quota", 30)
+	NoError(t, err)
+	count, err = c.IncrWithExpire("quota", 2, time.Minute)
+	NoError(t, err)
+	Equal(t, int64(4), count)
+	// 后续的增加不会重置窗口
+	ttl, err := c.TTL("quota")
+	NoError(t, err)
+	Equal(t, int64(30), ttl)
+	_, err = c.IncrWithExpire("quota", 1, 0)
+	Error(t, err)
+}
+
+func TestGetStream(t *testing.T) {
+	var err error
+	c := getCacher()
+	report := strings.Repeat("0123456789", 1000)
+	err = c.Set("report", report, 10)
+	NoError(t, err)
+	var buf bytes.Buffer
+	err = c.GetStream("report", &buf, 3000)
+	NoError(t, err)
+	Equal(t, report, buf.String())
+
+	c.Del("missing")
+	err = c.GetStream("missing", &buf, 0)
+	Equal(t, ErrNil, err)
+}
+
+func TestSetStream(t *testing.T) {
+	var err error
+	c := getCacher()
+	report := strings.Repeat("0123456789", 10000)
+	err = c.SetStream("report", strings.NewReader(report), 30)
+	NoError(t, err)
+	value, err := c.GetString("report")
+	NoError(t, err)
+	Equal(t, report, value)
+	ttl, err := c.TTL("report")
+	NoError(t, err)
+	Equal(t, int64(30), ttl)
+
+	err = c.SetStream("report", strings.NewReader(""), 0)
+	NoError(t, err)
+	value, err = c.GetString("report")
+	NoError(t, err)
+	Equal(t, "", value)
+}
+
+func TestSetAndPublish(t *testing.T) {
+	c := getCacher()
+	received := make(chan []byte, 1)
+	err := c.Subscribe(func(channel string, data []byte) error {
+		received <- data
+		return nil
+	}, "user_updates")
+	NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	err = c.SetAndPublish("user", User{Name: "corel", Age: 23}, 30, "user_updates")
+	NoError(t, err)
+	var user User
+	err = c.GetObject("user", &user)
+	NoError(t, err)
+	Equal(t, User{Name: "corel", Age: 23}, user)
+	select {
+	case data := <-received:
+		Equal(t, `{"Name":"corel","Age":23}`, string(data))
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func TestScan(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.Set("user:1", User{Name: "corel", Age: 23}, 10)
+	NoError(t, err)
+	err = c.Set("user:2", User{Name: "zen", Age: 18}, 10)
+	NoError(t, err)
+
+	var user User
+	reply, err := c.Do("GET", "zengate_user:1")
+	err = c.Scan(reply, err, &user)
+	NoError(t, err)
+	Equal(t, User{Name: "corel", Age: 23}, user)
+
+	var users []User
+	reply, err = c.Do("MGET", "zengate_user:1", "zengate_user:2")
+	err = c.Scan(reply, err, &users)
+	NoError(t, err)
+	Equal(t, []User{{Name: "corel", Age: 23}, {Name: "zen", Age: 18}}, users)
+
+	var name string
+	reply, err = c.Do("ECHO", "corel")
+	err = c.Scan(reply, err, &name)
+	NoError(t, err)
+	Equal(t, "corel", name)
+
+	reply, err = c.Do("MGET", "zengate_user:1")
+	err = c.Scan(reply, err, &user)
+	Error(t, err)
+
+	// 字符串、[]byte 和布尔类型保存时没有序列化，Scan 直接转换
+	NoError(t, c.Set("scan_string", "corel", 10))
+	NoError(t, c.Set("scan_bytes", []byte{0x00, 0xff}, 10))
+	NoError(t, c.Set("scan_bool", true, 10))
+	stored, err := testServer.Get("zengate_scan_bytes")
+	NoError(t, err)
+	Equal(t, string([]byte{0x00, 0xff}), stored)
+	var data []byte
+	var flag bool
+	reply, err = c.Do("GET", "zengate_scan_string")
+	NoError(t, c.Scan(reply, err, &name))
+	Equal(t, "corel", name)
+	reply, err = c.Do("GET", "zengate_scan_bytes")
+	NoError(t, c.Scan(reply, err, &data))
+	Equal(t, []byte{0x00, 0xff}, data)
+	reply, err = c.Do("GET", "zengate_scan_bool")
+	NoError(t, c.Scan(reply, err, &flag))
+	Equal(t, true, flag)
+}
+
+func TestScanNumbersWithCodec(t *testing.T) {
+	// 数字保存时没有序列化，使用gob等非json的反序列化方法或者设置了格式标记时也能读取
+	gobCacher, err := New(Options{
+		Addr:   testServer.Addr(),
+		Prefix: "zengate_",
+		Marshal: func(v interface{}) ([]byte, error) {
+			var buf bytes.Buffer
+			err := gob.NewEncoder(&buf).Encode(v)
+			return buf.Bytes(), err
+		},
+		Unmarshal: func(data []byte, v interface{}) error {
+			return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+		},
+		FormatTag: 2,
+	})
+	NoError(t, err)
+
+	NoError(t, gobCacher.Set("scan_int", 23, 10))
+	NoError(t, gobCacher.Set("scan_float", 2.5, 10))
+	var n int
+	var small int8
+	var f float64
+	reply, err := gobCacher.Do("GET", "zengate_scan_int")
+	NoError(t, gobCacher.Scan(reply, err, &n))
+	Equal(t, 23, n)
+	reply, err = gobCacher.Do("GET", "zengate_scan_int")
+	NoError(t, gobCacher.Scan(reply, err, &small))
+	Equal(t, int8(23), small)
+	reply, err = gobCacher.Do("GET", "zengate_scan_float")
+	NoError(t, gobCacher.Scan(reply, err, &f))
+	Equal(t, 2.5, f)
+	NoError(t, gobCacher.Set("scan_int", 300, 10))
+	reply, err = gobCacher.Do("GET", "zengate_scan_int")
+	Error(t, gobCacher.Scan(reply, err, &small))
+
+	ages := NewTypedCache[int](gobCacher)
+	NoError(t, ages.Set("typed_age", 23, 10))
+	age, err := ages.Get("typed_age")
+	NoError(t, err)
+	Equal(t, 23, age)
+
+	queue := NewQueue[int](gobCacher, "scan_queue")
+	gobCacher.Del("scan_queue")
+	NoError(t, queue.Push(18))
+	age, err = queue.Pop()
+	NoError(t, err)
+	Equal(t, 18, age)
+}
+
+func TestLegacyBytesValue(t *testing.T) {
+	c := getCacher()
+	// 早期版本的Set把[]byte序列化为json的base64字符串
+	legacy, err := json.Marshal([]byte{0x00, 0xff, 'a'})
+	NoError(t, err)
+	testServer.Set(c.Key("legacy_bytes"), string(legacy))
+
+	var data []byte
+	err = c.GetObject("legacy_bytes", &data)
+	NoError(t, err)
+	Equal(t, []byte{0x00, 0xff, 'a'}, data)
+
+	// 重新写入后保存的是原始字节
+	NoError(t, c.Set("legacy_bytes", data, 10))
+	stored, err := testServer.Get(c.Key("legacy_bytes"))
+	NoError(t, err)
+	Equal(t, string([]byte{0x00, 0xff, 'a'}), stored)
+	reply, err := c.Do("GET", c.Key("legacy_bytes"))
+	NoError(t, c.Scan(reply, err, &data))
+	Equal(t, []byte{0x00, 0xff, 'a'}, data)
+}
+
+func TestExistsMap(t *testing.T) {
+	c := getCacher()
+	c.Del("missing")
+	err := c.Set("name", "corel", 10)
+	NoError(t, err)
+	result, err := c.ExistsMap("name", "missing")
+	NoError(t, err)
+	Equal(t, map[string]bool{"name": true, "missing": false}, result)
+	_, err = c.ExistsMap("name", "")
+	Equal(t, ErrEmptyKey, err)
+}
+
+func TestSafeIncr(t *testing.T) {
+	c := getCacher()
+	c.Del("orders")
+	count, err := c.SafeIncr("orders", "request-1", time.Minute)
+	NoError(t, err)
+	Equal(t, int64(1), count)
+	// 重试时不会重复增加
+	count, err = c.SafeIncr("orders", "request-1", time.Minute)
+	NoError(t, err)
+	Equal(t, int64(1), count)
+	count, err = c.SafeIncr("orders", "request-2", time.Minute)
+	NoError(t, err)
+	Equal(t, int64(2), count)
+	_, err = c.SafeIncr("", "request-3", time.Minute)
+	Equal(t, ErrEmptyKey, err)
+}
+
+func TestHGetAllObjects(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("users")
+	_, err = c.HSet("users", "1", User{Name: "corel", Age: 23})
+	NoError(t, err)
+	_, err = c.HSet("users", "2", User{Name: "zen", Age: 18})
+	NoError(t, err)
+	users, err := c.HGetAllObjects("users", func(field string) interface{} { return &User{} })
+	NoError(t, err)
+	Equal(t, map[string]interface{}{"1": &User{Name: "corel", Age: 23}, "2": &User{Name: "zen", Age: 18}}, users)
+}
+
+func TestWithConnReset(t *testing.T) {
+	// miniredis没有实现 RESET ，这里只记录调用次数，连接的数据库由之后重新执行的 SELECT 恢复
+	resets := 0
+	version := ""
+	options := Options{MaxIdle: 1, MaxActive: 1, Wait: true}
+	c, s := getHookedCacher(t, options, func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool {
+		if writeServerInfo(p, cmd, version) {
+			return true
+		}
+		if cmd != "RESET" {
+			return false
+		}
+		resets++
+		p.WriteInline("RESET")
+		return true
+	})
+
+	var err error
+	failed := errors.New("abandoned")
+	for _, version = range []string{"7.0.0", "6.0.9"} {
+		// Reset 时重新获取服务版本
+		options.Addr = s.Addr()
+		options.DisableSignalClose = true
+		NoError(t, c.Reset(options))
+		Equal(t, version, c.ServerVersion())
+		// 切换到其他数据库后出错返回，连接如果不恢复，之后的命令都会在数据库1上执行
+		err = c.WithConn(func(conn redis.Conn) error {
+			if _, err := conn.Do("SELECT", 1); err != nil {
+				return err
+			}
+			return failed
+		})
+		Equal(t, failed, err)
+		NoError(t, c.Set("name", version, 0))
+		name, err := s.DB(0).Get("name")
+		NoError(t, err)
+		Equal(t, version, name)
+	}
+	Equal(t, 1, resets)
+}
+
+func TestWatch(t *testing.T) {
+	c := getCacher()
+	err := c.Set("balance", 100, 10)
+	NoError(t, err)
+	err = c.WithConn(func(conn redis.Conn) error {
+		if err := c.Watch(conn, "balance"); err != nil {
+			return err
+		}
+		balance, err := redis.Int64(conn.Do("GET", c.Key("balance")))
+		if err != nil {
+			return err
+		}
+		// 其他客户端在事务执行前修改了键
+		if err := c.Set("balance", 50, 10); err != nil {
+			return err
+		}
+		conn.Send("MULTI")
+		conn.Send("SET", c.Key("balance"), balance-10)
+		reply, err := conn.Do("EXEC")
+		Equal(t, nil, reply)
+		return err
+	})
+	NoError(t, err)
+	balance, err := c.GetInt64("balance")
+	NoError(t, err)
+	Equal(t, int64(50), balance)
+
+	err = c.WithConn(func(conn redis.Conn) error {
+		if err := c.Watch(conn, "balance"); err != nil {
+			return err
+		}
+		return c.Unwatch(conn)
+	})
+	NoError(t, err)
+}
+
+func TestAccessors(t *testing.T) {
+	marshal := func(v interface{}) ([]byte, error) { return []byte("custom"), nil }
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "app", PrefixSeparator: ":", Marshal: marshal, DisableSignalClose: true})
+	NoError(t, err)
+	Equal(t, "app:", c.Prefix())
+	Equal(t, "", c.NoPrefix().Prefix())
+	data, err := c.Marshaler()(User{Name: "corel"})
+	NoError(t, err)
+	Equal(t, "custom", string(data))
+	var user User
+	err = c.Unmarshaler()([]byte(`{"Name":"corel"}`), &user)
+	NoError(t, err)
+	Equal(t, "corel", user.Name)
+}
+
+func TestConsume(t *testing.T) {
+	// 6.2.0以上使用 GETDEL ，以下使用事务执行 GET 和 DEL
+	for _, version := range []string{"6.2.0", "6.0.9"} {
+		c := getVersionCacher(t, Options{Prefix: "zengate_"}, version)
+		err := c.Set("token", User{Name: "corel", Age: 23}, 10)
+		NoError(t, err)
+		var user User
+		ok, err := c.Consume("token", &user)
+		NoError(t, err)
+		Equal(t, true, ok)
+		Equal(t, User{Name: "corel", Age: 23}, user)
+		ok, err = c.Consume("token", &user)
+		NoError(t, err)
+		Equal(t, false, ok)
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	c := getCacher()
+	c.Del("user_index")
+	_, err := c.Do("SADD", c.Key("user_index"), c.Key("user:1"), c.Key("user:2"))
+	NoError(t, err)
+	members, err := redis.Strings(c.Do("SMEMBERS", c.Key("user_index")))
+	NoError(t, err)
+	sort.Strings(members)
+	for i, member := range members {
+		members[i] = c.StripPrefix(member)
+	}
+	Equal(t, []string{"user:1", "user:2"}, members)
+	Equal(t, "other:1", c.StripPrefix("other:1"))
+}
+
+func TestKeyRouter(t *testing.T) {
+	router := func(key string) (string, int) {
+		if strings.HasPrefix(key, "t1:") {
+			return "tenant1", 1
+		}
+		return "shared", 0
+	}
+	c, err := New(Options{Addr: testServer.Addr(), PrefixSeparator: ":", KeyRouter: router, DisableSignalClose: true})
+	NoError(t, err)
+	defer c.Close()
+	Equal(t, "tenant1:t1:name", c.Key("t1:name"))
+	Equal(t, "shared:name", c.Key("name"))
+
+	tc, err := c.Route("t1:name")
+	NoError(t, err)
+	NoError(t, tc.Set("t1:name", "corel", 10))
+	same, err := c.Route("name")
+	NoError(t, err)
+	Equal(t, c, same)
+
+	stored, err := testServer.DB(1).Get("tenant1:t1:name")
+	NoError(t, err)
+	Equal(t, "corel", stored)
+	Equal(t, false, testServer.Exists("tenant1:t1:name"))
+	again, err := c.Route("t1:other")
+	NoError(t, err)
+	name, err := again.GetString("t1:name")
+	NoError(t, err)
+	Equal(t, "corel", name)
+
+	Equal(t, "t1:name", c.StripPrefix("tenant1:t1:name"))
+	Equal(t, "name", c.StripPrefix("shared:name"))
+	Equal(t, "other:name", c.StripPrefix("other:name"))
+
+	// ForEachDB 中通过 Route 创建的连接池在遍历完后关闭
+	var routed []*Cacher
+	err = c.ForEachDB(func(db int, dc *Cacher) error {
+		if db != 3 {
+			return nil
+		}
+		rc, err := dc.Route("t1:name")
+		routed = append(routed, rc)
+		return err
+	})
+	NoError(t, err)
+	Equal(t, 1, len(routed))
+	if len(routed) == 1 {
+		_, err = routed[0].GetString("t1:name")
+		Equal(t, true, errors.Is(err, ErrClosed))
+	}
+
+	// 路由后的前缀无法从 match 推断，遍历方法返回错误而不是空结果
+	_, _, err = c.ScanPage(0, "", 0)
+	Equal(t, errScanWithKeyRouter, err)
+	var buf bytes.Buffer
+	err = c.Export(&buf)
+	Equal(t, errScanWithKeyRouter, err)
+	Equal(t, 0, buf.Len())
+	_, err = c.FindKeysWithoutTTL("", 0)
+	Equal(t, errScanWithKeyRouter, err)
+}
+
+func TestServerTime(t *testing.T) {
+	c := getCacher()
+	now, err := c.ServerTime()
+	NoError(t, err)
+	if d := time.Since(now); d > 5*time.Second || d < -5*time.Second {
+		t.Errorf("expected server time close to local time, got %s", now)
+	}
+}
+
+func TestFindKeysWithoutTTL(t *testing.T) {
+	c := getCacher().WithPrefix("ttl_audit")
+	NoError(t, c.Set("leak:1", "forgot", 0))
+	NoError(t, c.Set("leak:2", "forgot", 0))
+	NoError(t, c.Set("ok", "expires", 60))
+	keys, err := c.FindKeysWithoutTTL("", 1)
+	NoError(t, err)
+	sort.Strings(keys)
+	Equal(t, []string{"leak:1", "leak:2"}, keys)
+	keys, err = c.FindKeysWithoutTTL("leak:2", 0)
+	NoError(t, err)
+	Equal(t, []string{"leak:2"}, keys)
+}
+
+func TestSetKeepTTL(t *testing.T) {
+	c := getCacher()
+	NoError(t, c.Set("name", "corel", 100))
+	NoError(t, c.SetKeepTTL("name", User{Name: "zen"}))
+	ttl, err := c.TTL("name")
+	NoError(t, err)
+	Equal(t, int64(100), ttl)
+	var user User
+	NoError(t, c.GetObject("name", &user))
+	Equal(t, "zen", user.Name)
+
+	c = getVersionCacher(t, Options{}, "5.0.7")
+	err = c.SetKeepTTL("name", "corel")
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestAssertEncoding(t *testing.T) {
+	// miniredis没有实现 OBJECT ENCODING
+	c, _ := getHookedCacher(t, Options{Prefix: "app"}, func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool {
+		if cmd != "OBJECT" || !strings.EqualFold(args[0], "ENCODING") {
+			return false
+		}
+		if s.Exists(args[1]) {
+			p.WriteBulk("listpack")
+		} else {
+			p.WriteNull()
+		}
+		return true
+	})
+	_, err := c.HSet("small", "name", "corel")
+	NoError(t, err)
+	NoError(t, c.AssertEncoding("small", "listpack"))
+	Error(t, c.AssertEncoding("small", "hashtable"))
+	Equal(t, ErrNil, c.AssertEncoding("missing", "listpack"))
+}
+
+func TestAppendRecord(t *testing.T) {
+	c := getCacher()
+	c.Del("events")
+	for i, record := range []string{"login\x00\x00\x00", "logout\x00\x00"} {
+		index, err := c.AppendRecord("events", []byte(record), 8)
+		NoError(t, err)
+		Equal(t, int64(i), index)
+	}
+	second, err := String(c.Do("GETRANGE", c.Key("events"), 8, 15))
+	NoError(t, err)
+	Equal(t, "logout\x00\x00", second)
+
+	_, err = c.AppendRecord("events", []byte("short"), 8)
+	Error(t, err)
+	_, err = c.AppendRecord("events", []byte("abcd"), 4)
+	NoError(t, err)
+	_, err = c.AppendRecord("events", []byte("login\x00\x00\x00"), 8)
+	Error(t, err)
+}
+
+func TestHMSetFlattening(t *testing.T) {
+	c := getCacher()
+	c.Del("event")
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	type address struct {
+		City string
+		Zip  int
+	}
+	event := struct {
+		Name    string
+		Created time.Time
+		Address address
+		Raw     []byte
+	}{"login", created, address{"shanghai", 200000}, []byte{0, 1, 2}}
+	err := c.HMSet("event", event, 10)
+	NoError(t, err)
+	// HMSet 使用redigo展开结构体，time.Time 和嵌套的结构体按 fmt 的格式保存，[]byte 原样保存
+	value, err := String(c.HGet("event", "Created"))
+	NoError(t, err)
+	Equal(t, created.String(), value)
+	value, err = String(c.HGet("event", "Address"))
+	NoError(t, err)
+	Equal(t, "{shanghai 200000}", value)
+	raw, err := redis.Bytes(c.HGet("event", "Raw"))
+	NoError(t, err)
+	Equal(t, []byte{0, 1, 2}, raw)
+	ttl, err := c.TTL("event")
+	NoError(t, err)
+	Equal(t, int64(10), ttl)
+
+	// HSet 使用配置的序列化方法，可以反序列化回 time.Time
+	_, err = c.HSet("event", "Created", created)
+	NoError(t, err)
+	var got time.Time
+	reply, err := c.HGet("event", "Created")
+	NoError(t, c.Scan(reply, err, &got))
+	Equal(t, true, created.Equal(got))
+}
+
+func TestLRangeTyped(t *testing.T) {
+	c := getCacher()
+	c.Del("names")
+	c.RPush("names", "corel")
+	c.RPush("names", "zen")
+	names, err := c.LRangeStrings("names", 0, -1)
+	NoError(t, err)
+	Equal(t, []string{"corel", "zen"}, names)
+
+	c.Del("scores")
+	c.RPush("scores", 82)
+	c.RPush("scores", 86)
+	scores, err := c.LRangeInts("scores", 0, 0)
+	NoError(t, err)
+	Equal(t, []int{82}, scores)
+	_, err = c.LRangeInts("names", 0, -1)
+	Error(t, err)
+}
+
+func TestTTLJitter(t *testing.T) {
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "jitter", TTLJitter: 0.1, DisableSignalClose: true})
+	NoError(t, err)
+	defer c.Close()
+	for i := 0; i < 20; i++ {
+		NoError(t, c.Set("batch", i, 1000))
+		ttl, err := c.TTL("batch")
+		NoError(t, err)
+		if ttl < 900 || ttl > 1100 {
+			t.Fatalf("expected ttl within 900-1100, got %d", ttl)
+		}
+	}
+	NoError(t, c.Set("batch", "forever", 0))
+	ttl, err := c.TTL("batch")
+	NoError(t, err)
+	Equal(t, int64(-1), ttl)
+
+	_, err = New(Options{Addr: testServer.Addr(), TTLJitter: 1.5, DisableSignalClose: true})
+	Error(t, err)
+}
+
+func TestExistsFresh(t *testing.T) {
+	c, err := New(Options{Addr: testServer.Addr(), Prefix: "fresh", ExistsFreshGrace: 2 * time.Second, DisableSignalClose: true})
+	NoError(t, err)
+	defer c.Close()
+	c.Del("missing")
+	NoError(t, c.Set("forever", "corel", 0))
+	NoError(t, c.Set("expiring", "corel", 1))
+	NoError(t, c.Set("fresh", "corel", 10))
+	for key, expected := range map[string]bool{"missing": false, "forever": true, "expiring": false, "fresh": true} {
+		ok, err := c.ExistsFresh(key)
+		NoError(t, err)
+		Equal(t, expected, ok)
+	}
+	exists, err := c.Exists("expiring")
+	NoError(t, err)
+	Equal(t, true, exists)
+}
+
+func TestCommandTimeout(t *testing.T) {
+	c, _ := getHookedCacher(t, Options{CommandTimeout: 100 * time.Millisecond}, func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool {
+		if cmd == "ECHO" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		return false
+	})
+
+	_, err := c.Do("ECHO", "slow")
+	var netErr net.Error
+	Equal(t, true, errors.As(err, &netErr) && netErr.Timeout())
+
+	// 阻塞命令不受 CommandTimeout 限制
+	start := time.Now()
+	_, err = c.BLPop("empty", 1)
+	Equal(t, ErrNil, err)
+	if d := time.Since(start); d < time.Second {
+		t.Errorf("expected BLPOP to wait for its own timeout, returned after %s", d)
+	}
+	NoError(t, c.Set("name", "corel", 0))
+	name, err := c.GetString("name")
+	NoError(t, err)
+	Equal(t, "corel", name)
+}
+
+func TestExpireWithFlag(t *testing.T) {
+	c := getCacher()
+	NoError(t, c.Set("session", "corel", 0))
+	ok, err := c.ExpireWithFlag("session", 100, "xx")
+	NoError(t, err)
+	Equal(t, false, ok)
+	ok, err = c.ExpireWithFlag("session", 100, "NX")
+	NoError(t, err)
+	Equal(t, true, ok)
+	ok, err = c.ExpireWithFlag("session", 50, "GT")
+	NoError(t, err)
+	Equal(t, false, ok)
+	ok, err = c.ExpireWithFlag("session", 200, "GT")
+	NoError(t, err)
+	Equal(t, true, ok)
+	ttl, err := c.TTL("session")
+	NoError(t, err)
+	Equal(t, int64(200), ttl)
+
+	_, err = c.ExpireWithFlag("session", 10, "ALWAYS")
+	Error(t, err)
+	c = getVersionCacher(t, Options{}, "6.2.6")
+	_, err = c.ExpireWithFlag("session", 10, "LT")
+	Equal(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestRotateCounter(t *testing.T) {
+	c := getCacher()
+	c.Del("page_views")
+	c.Del("page_views_history")
+	for i := 0; i < 3; i++ {
+		_, err := c.Incr("page_views")
+		NoError(t, err)
+	}
+	old, err := c.RotateCounter("page_views", "page_views_history", "2024-01-01")
+	NoError(t, err)
+	Equal(t, int64(3), old)
+	count, err := c.GetInt64("page_views")
+	NoError(t, err)
+	Equal(t, int64(0), count)
+	archived, err := Int64(c.HGet("page_views_history", "2024-01-01"))
+	NoError(t, err)
+	Equal(t, int64(3), archived)
+
+	c.Del("page_views")
+	old, err = c.RotateCounter("page_views", "page_views_history", "2024-01-02")
+	NoError(t, err)
+	Equal(t, int64(0), old)
+}
+
+func TestGetOr(t *testing.T) {
+	c := getCacher()
+	c.Del("missing")
+	err := c.Set("name", "corel", 10)
+	NoError(t, err)
+	err = c.Set("age", 23, 10)
+	NoError(t, err)
+	Equal(t, "corel", c.GetStringOr("name", "guest"))
+	Equal(t, "guest", c.GetStringOr("missing", "guest"))
+	Equal(t, 23, c.GetIntOr("age", 18))
+	Equal(t, int64(18), c.GetInt64Or("missing", 18))
+	Equal(t, true, c.GetBoolOr("missing", true))
+
+	down := getFaultCacher(0, errors.New("connection refused"))
+	Equal(t, "guest", down.GetStringOr("name", "guest"))
+}
+
+func TestBinaryKeys(t *testing.T) {
+	var err error
+	c := getCacher()
+	key := "hash:\x00\xff\xfe\x00end"
+	err = c.Set(key, "corel", 10)
+	NoError(t, err)
+	Equal(t, true, testServer.Exists("zengate_"+key))
+	name, err := c.GetString(key)
+	NoError(t, err)
+	Equal(t, "corel", name)
+	_, err = c.GetString("hash:\x00\xff\xfe\x00")
+	Equal(t, ErrNil, err)
+
+	err = c.Set(key, User{Name: "co\x00rel", Age: 23}, 10)
+	NoError(t, err)
+	var user User
+	err = c.GetObject(key, &user)
+	NoError(t, err)
+	Equal(t, "co\x00rel", user.Name)
+
+	data := []byte{0x00, 0x1f, 0x8b, 0xff}
+	_, err = c.Do("SET", c.Key(key), data)
+	NoError(t, err)
+	var raw []byte
+	reply, err := c.Do("GET", c.Key(key))
+	err = c.Scan(reply, err, &raw)
+	NoError(t, err)
+	Equal(t, data, raw)
+}
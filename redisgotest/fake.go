@@ -0,0 +1,35 @@
+// Package redisgotest 提供基于 miniredis 的 redisgo.Cacher，用于在没有redis服务的环境中测试使用了redisgo的代码。
+// 这个包只应该在测试代码中引用，以免把miniredis引入到正式的程序中。
+// miniredis 只实现了部分redis命令，并且键的过期需要调用 Miniredis.FastForward 推进时间。
+package redisgotest
+
+import (
+	"github.com/aiscrm/redisgo"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// NewFake 启动一个内存中的miniredis服务，并返回连接到该服务的Cacher。不会注册关闭连接池并退出进程的信号处理。
+// miniredis服务会一直运行到程序退出。
+func NewFake() (*redisgo.Cacher, error) {
+	c, _, err := NewFakeWithOptions(redisgo.Options{})
+	return c, err
+}
+
+// NewFakeWithOptions 启动一个内存中的miniredis服务，并使用options创建连接到该服务的Cacher。
+// options中的Network和Addr会被替换为miniredis服务的地址，DisableSignalClose 总是设置为true，以免收到信号时退出测试进程。
+// 返回的miniredis服务可以用来推进时间、检查数据，使用完后调用其Close方法关闭。
+func NewFakeWithOptions(options redisgo.Options) (*redisgo.Cacher, *miniredis.Miniredis, error) {
+	s, err := miniredis.Run()
+	if err != nil {
+		return nil, nil, err
+	}
+	options.Network = "tcp"
+	options.Addr = s.Addr()
+	options.DisableSignalClose = true
+	c, err := redisgo.New(options)
+	if err != nil {
+		s.Close()
+		return nil, nil, err
+	}
+	return c, s, nil
+}
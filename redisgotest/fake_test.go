@@ -0,0 +1,68 @@
+package redisgotest
+
+import (
+	"os"
+	"os/signal"
+	"testing"
+	"time"
+
+	"github.com/aiscrm/redisgo"
+)
+
+func TestNewFake(t *testing.T) {
+	c, err := NewFake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("name", "corel", 0); err != nil {
+		t.Fatal(err)
+	}
+	name, err := c.GetString("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "corel" {
+		t.Errorf("expected corel, got %s", name)
+	}
+}
+
+func TestNewFakeWithOptions(t *testing.T) {
+	c, s, err := NewFakeWithOptions(redisgo.Options{Prefix: "test_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := c.Set("name", "corel", 1); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Exists("test_name") {
+		t.Error("expected key test_name to exist")
+	}
+	s.FastForward(2 * time.Second)
+	if s.Exists("test_name") {
+		t.Error("expected key test_name to be expired")
+	}
+}
+
+func TestNewFakeIgnoresSignals(t *testing.T) {
+	c, err := NewFake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 收到信号后连接池仍然可用，进程也没有退出
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	defer signal.Stop(ch)
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("sending signals is not supported: %v", err)
+	}
+	<-ch
+	time.Sleep(50 * time.Millisecond)
+	if err := c.Set("name", "corel", 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,91 @@
+package redisgo
+
+import (
+	"fmt"
+	"time"
+)
+
+// SessionStore 基于redis的会话存储，每个会话序列化后（与 Set 相同）保存在一个键中，键名为 prefix 加上会话ID（还会加上Cacher的前缀）。
+// 会话使用滑动过期：每次 Get 时通过 GETEX 将有效期重新设置为 ttl ，长时间不访问的会话自动过期。GETEX 需要redis 6.2及以上版本。
+// Example:
+//
+// ```golang
+// sessions := redisgo.NewSessionStore(c, "session:", 30*time.Minute)
+// err := sessions.Set(sid, user, 0)
+// err = sessions.Get(sid, &user)
+// ```
+type SessionStore struct {
+	c      *Cacher
+	prefix string
+	ttl    time.Duration
+}
+
+// NewSessionStore 创建会话存储，prefix 为会话键名的前缀，ttl 为会话默认的有效期（精度为毫秒），应该大于0。
+func NewSessionStore(c *Cacher, prefix string, ttl time.Duration) *SessionStore {
+	return &SessionStore{c: c, prefix: prefix, ttl: ttl}
+}
+
+// Get 读取会话数据并反序列化到 dest ，同时将会话的有效期重新设置为默认的 ttl 。会话不存在或已过期时返回 ErrNil 。
+func (s *SessionStore) Get(sessionID string, dest interface{}) error {
+	key, err := s.key(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := s.c.checkSupport("GETEX"); err != nil {
+		return err
+	}
+	reply, err := s.c.Do("GETEX", s.c.getKey(key), "PX", s.ttl.Milliseconds())
+	return s.c.decode(reply, err, dest)
+}
+
+// Set 保存会话数据，ttl 为会话的有效期，值为0时使用默认的 ttl 。已存在的会话会被覆盖。
+func (s *SessionStore) Set(sessionID string, data interface{}, ttl time.Duration) error {
+	key, err := s.key(sessionID)
+	if err != nil {
+		return err
+	}
+	if ttl == 0 {
+		ttl = s.ttl
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("redisgo: SessionStore: ttl must be positive, got %s", ttl)
+	}
+	value, err := s.c.encode(data)
+	if err != nil {
+		return err
+	}
+	_, err = s.c.Do("SET", s.c.getKey(key), value, "PX", ttl.Milliseconds())
+	return err
+}
+
+// Touch 将会话的有效期重新设置为 ttl 而不读取会话数据，值为0时使用默认的 ttl 。返回会话是否存在。
+func (s *SessionStore) Touch(sessionID string, ttl time.Duration) (bool, error) {
+	key, err := s.key(sessionID)
+	if err != nil {
+		return false, err
+	}
+	if ttl == 0 {
+		ttl = s.ttl
+	}
+	if ttl <= 0 {
+		return false, fmt.Errorf("redisgo: SessionStore: ttl must be positive, got %s", ttl)
+	}
+	return Bool(s.c.Do("PEXPIRE", s.c.getKey(key), ttl.Milliseconds()))
+}
+
+// Destroy 删除会话，会话不存在时不返回错误。
+func (s *SessionStore) Destroy(sessionID string) error {
+	key, err := s.key(sessionID)
+	if err != nil {
+		return err
+	}
+	return s.c.Del(key)
+}
+
+// key 返回会话的键名（不含Cacher的前缀）
+func (s *SessionStore) key(sessionID string) (string, error) {
+	if sessionID == "" {
+		return "", ErrEmptyKey
+	}
+	return s.prefix + sessionID, nil
+}
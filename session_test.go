@@ -0,0 +1,42 @@
+package redisgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionStore(t *testing.T) {
+	c := getCacher()
+	sessions := NewSessionStore(c, "session:", time.Minute)
+	err := sessions.Set("sid1", User{Name: "corel", Age: 23}, 10*time.Second)
+	NoError(t, err)
+	ttl, err := c.TTL("session:sid1")
+	NoError(t, err)
+	Equal(t, int64(10), ttl)
+
+	var user User
+	err = sessions.Get("sid1", &user)
+	NoError(t, err)
+	Equal(t, User{Name: "corel", Age: 23}, user)
+	// Get 将有效期刷新为默认的 ttl
+	ttl, err = c.TTL("session:sid1")
+	NoError(t, err)
+	Equal(t, int64(60), ttl)
+
+	ok, err := sessions.Touch("sid1", 2*time.Minute)
+	NoError(t, err)
+	Equal(t, true, ok)
+	ttl, err = c.TTL("session:sid1")
+	NoError(t, err)
+	Equal(t, int64(120), ttl)
+
+	err = sessions.Destroy("sid1")
+	NoError(t, err)
+	Equal(t, ErrNil, sessions.Get("sid1", &user))
+	ok, err = sessions.Touch("sid1", 0)
+	NoError(t, err)
+	Equal(t, false, ok)
+
+	Equal(t, ErrEmptyKey, sessions.Set("", user, 0))
+	Error(t, sessions.Set("sid2", user, -time.Second))
+}
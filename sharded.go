@@ -0,0 +1,167 @@
+package redisgo
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+)
+
+// shardReplicas 每个节点在一致性哈希环上的虚拟节点数量，数量越多键的分布越均匀
+const shardReplicas = 160
+
+// ShardedCacher 在多个独立的redis服务之间做客户端分片：使用一致性哈希为每个键选择节点，再交给该节点的Cacher执行。
+// 增减节点时只有少部分键会换到其他节点上。与redis集群一样，键名中包含 {tag} 时只使用tag计算哈希，
+// 可以让相关的键落在同一个节点上，以便使用多键操作。
+// Example:
+//
+// ```golang
+// sc, err := redisgo.NewShardedCacher(
+// redisgo.Options{Addr: "10.0.0.1:6379", Prefix: "app:"},
+// redisgo.Options{Addr: "10.0.0.2:6379", Prefix: "app:"},
+// )
+// err = sc.Set("user:1", user, 60)
+// ```
+type ShardedCacher struct {
+	nodes  []*Cacher
+	hashes []uint32 // 排好序的虚拟节点哈希值
+	owners map[uint32]int
+}
+
+// NewShardedCacher 为每个 Options 创建一个Cacher，并按节点的地址和数据库建立一致性哈希环。
+// 任何一个节点初始化失败时，关闭已经创建的节点并返回错误。
+func NewShardedCacher(options ...Options) (*ShardedCacher, error) {
+	if len(options) == 0 {
+		return nil, errors.New("redisgo: NewShardedCacher requires at least one node")
+	}
+	sc := &ShardedCacher{owners: make(map[uint32]int)}
+	for i, opts := range options {
+		c, err := New(opts)
+		if err != nil {
+			sc.Close()
+			return nil, fmt.Errorf("redisgo: shard %s: %w", opts.Addr, err)
+		}
+		sc.nodes = append(sc.nodes, c)
+		for r := 0; r < shardReplicas; r++ {
+			h := crc32.ChecksumIEEE([]byte(fmt.Sprintf("%s/%d-%d", opts.Addr, opts.Db, r)))
+			if _, ok := sc.owners[h]; ok {
+				continue
+			}
+			sc.owners[h] = i
+			sc.hashes = append(sc.hashes, h)
+		}
+	}
+	sort.Slice(sc.hashes, func(i, j int) bool { return sc.hashes[i] < sc.hashes[j] })
+	return sc, nil
+}
+
+// Nodes 返回所有节点的Cacher，可以用于 Flush 等需要在每个节点上执行的操作
+func (sc *ShardedCacher) Nodes() []*Cacher {
+	return sc.nodes
+}
+
+// Shard 返回键所在节点的Cacher，可以用于执行 ShardedCacher 没有直接提供的方法
+func (sc *ShardedCacher) Shard(key string) *Cacher {
+	h := crc32.ChecksumIEEE([]byte(hashTag(key)))
+	i := sort.Search(len(sc.hashes), func(i int) bool { return sc.hashes[i] >= h })
+	if i == len(sc.hashes) {
+		i = 0
+	}
+	return sc.nodes[sc.owners[sc.hashes[i]]]
+}
+
+// ShardFor 返回多个键共同所在节点的Cacher，用于执行多键操作。键分布在不同节点上时返回 ErrCrossShard 。
+func (sc *ShardedCacher) ShardFor(keys ...string) (*Cacher, error) {
+	if len(keys) == 0 {
+		return nil, ErrEmptyKey
+	}
+	c := sc.Shard(keys[0])
+	for _, key := range keys[1:] {
+		if sc.Shard(key) != c {
+			return nil, fmt.Errorf("%w: %s and %s", ErrCrossShard, keys[0], key)
+		}
+	}
+	return c, nil
+}
+
+// Close 关闭所有节点的连接池
+func (sc *ShardedCacher) Close() error {
+	var err error
+	for _, c := range sc.nodes {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Get 获取键值
+func (sc *ShardedCacher) Get(key string) (interface{}, error) {
+	return sc.Shard(key).Get(key)
+}
+
+// GetString 获取string类型的键值
+func (sc *ShardedCacher) GetString(key string) (string, error) {
+	return sc.Shard(key).GetString(key)
+}
+
+// GetInt 获取int类型的键值
+func (sc *ShardedCacher) GetInt(key string) (int, error) {
+	return sc.Shard(key).GetInt(key)
+}
+
+// GetInt64 获取int64类型的键值
+func (sc *ShardedCacher) GetInt64(key string) (int64, error) {
+	return sc.Shard(key).GetInt64(key)
+}
+
+// GetBool 获取bool类型的键值
+func (sc *ShardedCacher) GetBool(key string) (bool, error) {
+	return sc.Shard(key).GetBool(key)
+}
+
+// GetObject 获取非基本类型stuct的键值
+func (sc *ShardedCacher) GetObject(key string, val interface{}) error {
+	return sc.Shard(key).GetObject(key, val)
+}
+
+// Set 存并设置有效时长。时长的单位为秒。
+func (sc *ShardedCacher) Set(key string, val interface{}, expire int64) error {
+	return sc.Shard(key).Set(key, val, expire)
+}
+
+// Exists 检查键是否存在
+func (sc *ShardedCacher) Exists(key string) (bool, error) {
+	return sc.Shard(key).Exists(key)
+}
+
+// Del 删除键
+func (sc *ShardedCacher) Del(key string) error {
+	return sc.Shard(key).Del(key)
+}
+
+// TTL 以秒为单位。当 key 不存在时，返回 -2 。 当 key 存在但没有设置剩余生存时间时，返回 -1
+func (sc *ShardedCacher) TTL(key string) (int64, error) {
+	return sc.Shard(key).TTL(key)
+}
+
+// Expire 设置键过期时间，expire的单位为秒
+func (sc *ShardedCacher) Expire(key string, expire int64) error {
+	return sc.Shard(key).Expire(key, expire)
+}
+
+// Incr 将 key 中储存的数字值增一
+func (sc *ShardedCacher) Incr(key string) (int64, error) {
+	return sc.Shard(key).Incr(key)
+}
+
+// hashTag 返回键名中第一个非空的 {tag} ，没有时返回键名本身，规则与redis集群相同
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
@@ -0,0 +1,44 @@
+package redisgo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestShardedCacher(t *testing.T) {
+	s1 := miniredis.RunT(t)
+	s2 := miniredis.RunT(t)
+	sc, err := NewShardedCacher(
+		Options{Addr: s1.Addr(), Prefix: "zengate_", DisableSignalClose: true},
+		Options{Addr: s2.Addr(), Prefix: "zengate_", DisableSignalClose: true},
+	)
+	NoError(t, err)
+	defer sc.Close()
+
+	for i := 0; i < 20; i++ {
+		err = sc.Set(fmt.Sprintf("user:%d", i), User{Name: "corel", Age: i}, 30)
+		NoError(t, err)
+	}
+	for i := 0; i < 20; i++ {
+		var user User
+		err = sc.GetObject(fmt.Sprintf("user:%d", i), &user)
+		NoError(t, err)
+		Equal(t, i, user.Age)
+	}
+	// 键应该分布在两个节点上
+	Equal(t, true, len(s1.Keys()) > 0)
+	Equal(t, true, len(s2.Keys()) > 0)
+	Equal(t, 20, len(s1.Keys())+len(s2.Keys()))
+
+	c, err := sc.ShardFor("{user:1}:profile", "{user:1}:orders")
+	NoError(t, err)
+	Equal(t, sc.Shard("user:1"), c)
+	var crossErr error
+	for i := 1; i < 20 && crossErr == nil; i++ {
+		_, crossErr = sc.ShardFor("user:0", fmt.Sprintf("user:%d", i))
+	}
+	Equal(t, true, errors.Is(crossErr, ErrCrossShard))
+}
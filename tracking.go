@@ -0,0 +1,207 @@
+package redisgo
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// invalidateChannel redis发送键失效通知的频道
+const invalidateChannel = "__redis__:invalidate"
+
+// tracking 基于 CLIENT TRACKING 实现的客户端缓存。
+// redigo不支持RESP3的推送消息，所以这里使用RESP2的重定向模式：单独建立一个订阅 __redis__:invalidate 频道的连接，
+// 在这个连接上以广播模式（BCAST PREFIX <prefix>）开启跟踪并把失效通知重定向给自己，前缀下的键被任何客户端修改时都会收到通知，
+// 收到通知后删除本地缓存中对应的键。跟踪只绑定在这一个长期存在的连接上，连接池中的连接关闭或重建不会丢失通知。
+// 通知是异步到达的，所以通过同一个Cacher执行的写命令还会在执行后直接删除本地缓存中的键，保证能读到自己的写入。
+// 本地缓存没有容量限制，只适合读多写少且键数量可控的场景。
+type tracking struct {
+	mu       sync.Mutex
+	conn     redis.Conn // 接收失效通知的连接
+	clientID int64
+	prefix   string // 跟踪的键名前缀，只缓存这个前缀下的键
+	values   map[string]interface{}
+	loading  map[string]uint64 // 正在从redis读取的键，用于丢弃读取期间已经失效的值
+	seq      uint64
+	disabled bool
+}
+
+// newTracking 建立接收失效通知的连接，以广播模式跟踪 prefix 下的键（prefix 为空时跟踪所有键），并开始监听
+func newTracking(dial func() (redis.Conn, error), prefix string) (*tracking, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	clientID, err := redis.Int64(conn.Do("CLIENT", "ID"))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	args := redis.Args{"TRACKING", "ON", "REDIRECT", clientID, "BCAST"}
+	if prefix != "" {
+		args = args.Add("PREFIX", prefix)
+	}
+	if _, err := conn.Do("CLIENT", args...); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := conn.Do("SUBSCRIBE", invalidateChannel); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	t := &tracking{
+		conn:     conn,
+		clientID: clientID,
+		prefix:   prefix,
+		values:   make(map[string]interface{}),
+		loading:  make(map[string]uint64),
+	}
+	go t.listen(conn)
+	return t, nil
+}
+
+// get 优先返回本地缓存的值，没有时调用load从redis读取并缓存。不在跟踪前缀下的键收不到失效通知，直接从redis读取。
+func (t *tracking) get(key string, load func(key string) (interface{}, error)) (interface{}, error) {
+	if !strings.HasPrefix(key, t.prefix) {
+		return load(key)
+	}
+	t.mu.Lock()
+	if t.disabled {
+		t.mu.Unlock()
+		return load(key)
+	}
+	if v, ok := t.values[key]; ok {
+		t.mu.Unlock()
+		return v, nil
+	}
+	t.seq++
+	seq := t.seq
+	t.loading[key] = seq
+	t.mu.Unlock()
+
+	reply, err := load(key)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// 读取期间收到了失效通知，则不缓存这个可能已经过期的值
+	if t.loading[key] == seq {
+		delete(t.loading, key)
+		if err == nil && reply != nil && !t.disabled {
+			t.values[key] = reply
+		}
+	}
+	return reply, err
+}
+
+// listen 接收失效通知。通知的内容是失效的键名数组，为nil时表示整个库被清空（FLUSHDB/FLUSHALL）。
+// 由于redigo的PubSubConn无法解析数组类型的消息内容，这里直接读取原始回复。
+func (t *tracking) listen(conn redis.Conn) {
+	defer conn.Close()
+	for {
+		reply, err := redis.Values(conn.Receive())
+		if err != nil {
+			// 收不到失效通知就无法保证本地缓存的正确性，清空并停用本地缓存
+			t.disable()
+			return
+		}
+		if len(reply) != 3 {
+			continue
+		}
+		if kind, _ := redis.String(reply[0], nil); kind != "message" {
+			continue
+		}
+		switch keys := reply[2].(type) {
+		case nil:
+			t.flush()
+		case []interface{}:
+			for _, k := range keys {
+				if key, err := redis.String(k, nil); err == nil {
+					t.invalidate(key)
+				}
+			}
+		case []byte:
+			t.invalidate(string(keys))
+		}
+	}
+}
+
+// invalidate 删除本地缓存中的键
+func (t *tracking) invalidate(key string) {
+	t.mu.Lock()
+	delete(t.values, key)
+	delete(t.loading, key)
+	t.mu.Unlock()
+}
+
+// evict 在执行写命令后删除本地缓存中与参数同名的键。不解析各个命令的键名位置，参数中与缓存的键同名的都会删除，多删除只会多读一次redis。
+func (t *tracking) evict(args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.values) == 0 && len(t.loading) == 0 {
+		return
+	}
+	for _, arg := range args {
+		var key string
+		switch v := arg.(type) {
+		case string:
+			key = v
+		case []byte:
+			key = string(v)
+		default:
+			continue
+		}
+		delete(t.values, key)
+		delete(t.loading, key)
+	}
+}
+
+// flush 清空本地缓存
+func (t *tracking) flush() {
+	t.mu.Lock()
+	t.values = make(map[string]interface{})
+	t.loading = make(map[string]uint64)
+	t.mu.Unlock()
+}
+
+// close 关闭接收失效通知的连接，监听结束后本地缓存停用
+func (t *tracking) close() {
+	t.conn.Close()
+}
+
+// disable 清空并停用本地缓存，之后的读取都直接访问redis
+func (t *tracking) disable() {
+	t.mu.Lock()
+	t.disabled = true
+	t.values = make(map[string]interface{})
+	t.loading = make(map[string]uint64)
+	t.mu.Unlock()
+}
+
+// trackingReadCommands 不修改键的常用命令，执行后不需要删除本地缓存
+var trackingReadCommands = map[string]bool{
+	"GET":      true,
+	"MGET":     true,
+	"GETRANGE": true,
+	"STRLEN":   true,
+	"EXISTS":   true,
+	"TYPE":     true,
+	"TTL":      true,
+	"PTTL":     true,
+	"DUMP":     true,
+	"OBJECT":   true,
+	"MEMORY":   true,
+	"SCAN":     true,
+	"PING":     true,
+	"ECHO":     true,
+	"PUBLISH":  true,
+}
+
+// evictLocal 开启了 Tracking 时，在通过Cacher执行写命令后删除本地缓存中相关的键，不需要等待异步到达的失效通知
+func (c *Cacher) evictLocal(commandName string, args ...interface{}) {
+	t := c.state().tracking
+	if t == nil || trackingReadCommands[strings.ToUpper(commandName)] {
+		return
+	}
+	t.evict(args...)
+}
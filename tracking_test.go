@@ -0,0 +1,135 @@
+package redisgo
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// newTrackingCacher miniredis没有实现 CLIENT ID 和 CLIENT TRACKING ，这里模拟这两个命令并记录开启跟踪的参数
+func newTrackingCacher(t *testing.T) (*Cacher, *miniredis.Miniredis, *[][]string) {
+	var trackings [][]string
+	c, s := getHookedCacher(t, Options{Prefix: "app", Tracking: true}, func(s *miniredis.Miniredis, p *server.Peer, cmd string, args ...string) bool {
+		if cmd != "CLIENT" || len(args) == 0 {
+			return false
+		}
+		switch strings.ToUpper(args[0]) {
+		case "ID":
+			p.WriteInt(42)
+		case "TRACKING":
+			trackings = append(trackings, args)
+			p.WriteOK()
+		default:
+			return false
+		}
+		return true
+	})
+	return c, s, &trackings
+}
+
+func TestTrackingInvalidation(t *testing.T) {
+	c, s, trackings := newTrackingCacher(t)
+
+	// 只在接收通知的连接上以广播模式开启一次跟踪，连接池中的连接不开启
+	err := c.Set("name", "corel", 0)
+	NoError(t, err)
+	Equal(t, [][]string{{"TRACKING", "ON", "REDIRECT", "42", "BCAST", "PREFIX", "app"}}, *trackings)
+
+	name, err := c.GetString("name")
+	NoError(t, err)
+	Equal(t, "corel", name)
+
+	// 绕过Cacher直接修改的键，收到失效通知之前读取的是本地缓存
+	s.Set(c.Key("name"), "zen")
+	name, err = c.GetString("name")
+	NoError(t, err)
+	Equal(t, "corel", name)
+
+	s.Publish(invalidateChannel, c.Key("name"))
+	time.Sleep(100 * time.Millisecond)
+	name, err = c.GetString("name")
+	NoError(t, err)
+	Equal(t, "zen", name)
+
+	// 不在跟踪前缀下的键收不到失效通知，不使用本地缓存
+	other := c.WithPrefix("other")
+	err = other.Set("name", "corel", 0)
+	NoError(t, err)
+	_, err = other.GetString("name")
+	NoError(t, err)
+	s.Set(other.Key("name"), "zen")
+	name, err = other.GetString("name")
+	NoError(t, err)
+	Equal(t, "zen", name)
+}
+
+func TestTrackingReadYourWrites(t *testing.T) {
+	c, _, _ := newTrackingCacher(t)
+
+	// 没有发送失效通知，通过Cacher写入后也能立即读到新值
+	err := c.Set("name", "corel", 0)
+	NoError(t, err)
+	name, err := c.GetString("name")
+	NoError(t, err)
+	Equal(t, "corel", name)
+	err = c.Set("name", "zen", 0)
+	NoError(t, err)
+	name, err = c.GetString("name")
+	NoError(t, err)
+	Equal(t, "zen", name)
+
+	_, err = c.Incr("count")
+	NoError(t, err)
+	count, err := c.GetInt("count")
+	NoError(t, err)
+	Equal(t, 1, count)
+	_, err = c.Incr("count")
+	NoError(t, err)
+	count, err = c.GetInt("count")
+	NoError(t, err)
+	Equal(t, 2, count)
+
+	err = c.Del("name")
+	NoError(t, err)
+	_, err = c.GetString("name")
+	Equal(t, ErrNil, err)
+}
+
+// TestTrackingRedis 在真实的redis（6.0以上）上测试客户端缓存的失效通知，miniredis没有实现 CLIENT TRACKING 。
+// 设置了环境变量 REDISGO_TEST_ADDR 时才执行，比如 REDISGO_TEST_ADDR=127.0.0.1:6379 go test -run TestTrackingRedis
+func TestTrackingRedis(t *testing.T) {
+	addr := os.Getenv("REDISGO_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDISGO_TEST_ADDR is not set")
+	}
+	c, err := New(Options{Addr: addr, Prefix: "redisgo_tracking_", Tracking: true, DisableSignalClose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	writer, err := New(Options{Addr: addr, Prefix: "redisgo_tracking_", DisableSignalClose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	defer writer.Del("name")
+
+	NoError(t, c.Set("name", "corel", 60))
+	name, err := c.GetString("name")
+	NoError(t, err)
+	Equal(t, "corel", name)
+
+	// 其他客户端修改后，redis发送失效通知，之后读取到新的值
+	NoError(t, writer.Set("name", "zen", 60))
+	deadline := time.Now().Add(time.Second)
+	for name != "zen" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		name, err = c.GetString("name")
+		NoError(t, err)
+	}
+	Equal(t, "zen", name)
+}
@@ -0,0 +1,121 @@
+package redisgo
+
+import (
+	"github.com/gomodule/redigo/redis"
+)
+
+// TypedCache 基于Cacher的泛型缓存，值的类型在编译时确定，不需要再使用 GetInt、GetString、GetObject 等按类型区分的方法。
+// 值的序列化与Cacher相同：字符串、[]byte、数字和布尔类型直接保存，其他类型使用Cacher的序列化方法，所以与Cacher读写的数据可以互通。
+// Example:
+//
+// ```golang
+// users := redisgo.NewTypedCache[User](c)
+// err := users.Set("user:1", User{Name: "corel", Age: 23}, 60)
+// user, err := users.Get("user:1")
+// ```
+type TypedCache[T any] struct {
+	c *Cacher
+}
+
+// NewTypedCache 使用Cacher创建值类型为T的缓存，键名前缀、序列化方法等配置与Cacher相同
+func NewTypedCache[T any](c *Cacher) *TypedCache[T] {
+	return &TypedCache[T]{c: c}
+}
+
+// Get 获取键值
+func (tc *TypedCache[T]) Get(key string) (T, error) {
+	return tc.decode(tc.c.Get(key))
+}
+
+// Set 存并设置有效时长。时长的单位为秒。
+func (tc *TypedCache[T]) Set(key string, val T, expire int64) error {
+	return tc.c.Set(key, val, expire)
+}
+
+// Del 删除键
+func (tc *TypedCache[T]) Del(key string) error {
+	return tc.c.Del(key)
+}
+
+// LPush 将一个值插入到列表头部
+func (tc *TypedCache[T]) LPush(key string, val T) error {
+	return tc.c.LPush(key, val)
+}
+
+// RPush 将一个值插入到列表尾部
+func (tc *TypedCache[T]) RPush(key string, val T) error {
+	return tc.c.RPush(key, val)
+}
+
+// LPop 移出并获取列表中的第一个元素（表头，左边）
+func (tc *TypedCache[T]) LPop(key string) (T, error) {
+	return tc.decode(tc.c.LPop(key))
+}
+
+// RPop 移出并获取列表中的最后一个元素（表尾，右边）
+func (tc *TypedCache[T]) RPop(key string) (T, error) {
+	return tc.decode(tc.c.RPop(key))
+}
+
+// LRange 返回列表中指定区间内的元素，区间的用法与 Cacher.LRange 相同
+func (tc *TypedCache[T]) LRange(key string, start, end int) ([]T, error) {
+	return tc.decodeSlice(tc.c.LRange(key, start, end))
+}
+
+// SAdd 将一个或多个值加入到集合中，返回新加入的成员数量
+func (tc *TypedCache[T]) SAdd(key string, vals ...T) (int64, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	args := redis.Args{}.Add(tc.c.getKey(key))
+	for _, val := range vals {
+		value, err := tc.c.encode(val)
+		if err != nil {
+			return 0, err
+		}
+		args = args.Add(value)
+	}
+	return Int64(tc.c.Do("SADD", args...))
+}
+
+// SIsMember 判断值是否为集合的成员
+func (tc *TypedCache[T]) SIsMember(key string, val T) (bool, error) {
+	if key == "" {
+		return false, ErrEmptyKey
+	}
+	value, err := tc.c.encode(val)
+	if err != nil {
+		return false, err
+	}
+	return Bool(tc.c.Do("SISMEMBER", tc.c.getKey(key), value))
+}
+
+// SMembers 返回集合中的所有成员
+func (tc *TypedCache[T]) SMembers(key string) ([]T, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	return tc.decodeSlice(tc.c.Do("SMEMBERS", tc.c.getKey(key)))
+}
+
+// decode 使用 Cacher.Scan 将redis返回的值转换为T
+func (tc *TypedCache[T]) decode(reply interface{}, err error) (T, error) {
+	var val T
+	err = tc.c.Scan(reply, err, &val)
+	return val, err
+}
+
+// decodeSlice 将redis返回的数组转换为[]T
+func (tc *TypedCache[T]) decodeSlice(reply interface{}, err error) ([]T, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, len(values))
+	for i, value := range values {
+		if result[i], err = tc.decode(value, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package redisgo
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestTypedCache(t *testing.T) {
+	c := getCacher()
+
+	users := NewTypedCache[User](c)
+	err := users.Set("typed_user", User{Name: "corel", Age: 23}, 30)
+	NoError(t, err)
+	user, err := users.Get("typed_user")
+	NoError(t, err)
+	Equal(t, User{Name: "corel", Age: 23}, user)
+
+	names := NewTypedCache[string](c)
+	err = names.Set("typed_name", "corel", 30)
+	NoError(t, err)
+	name, err := names.Get("typed_name")
+	NoError(t, err)
+	Equal(t, "corel", name)
+
+	flags := NewTypedCache[bool](c)
+	err = flags.Set("typed_flag", true, 30)
+	NoError(t, err)
+	flag, err := flags.Get("typed_flag")
+	NoError(t, err)
+	Equal(t, true, flag)
+
+	blobs := NewTypedCache[[]byte](c)
+	err = blobs.Set("typed_blob", []byte{0x00, 0xff, 'a'}, 30)
+	NoError(t, err)
+	blob, err := blobs.Get("typed_blob")
+	NoError(t, err)
+	Equal(t, []byte{0x00, 0xff, 'a'}, blob)
+	c.Del("typed_blobs")
+	err = blobs.RPush("typed_blobs", []byte("corel"))
+	NoError(t, err)
+	blobList, err := blobs.LRange("typed_blobs", 0, -1)
+	NoError(t, err)
+	Equal(t, [][]byte{[]byte("corel")}, blobList)
+
+	ages := NewTypedCache[int](c)
+	c.Del("typed_ages")
+	err = ages.RPush("typed_ages", 23)
+	NoError(t, err)
+	err = ages.RPush("typed_ages", 18)
+	NoError(t, err)
+	list, err := ages.LRange("typed_ages", 0, -1)
+	NoError(t, err)
+	Equal(t, []int{23, 18}, list)
+
+	c.Del("typed_users")
+	n, err := users.SAdd("typed_users", User{Name: "corel", Age: 23}, User{Name: "zen", Age: 18})
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	ok, err := users.SIsMember("typed_users", User{Name: "zen", Age: 18})
+	NoError(t, err)
+	Equal(t, true, ok)
+	members, err := users.SMembers("typed_users")
+	NoError(t, err)
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	Equal(t, []User{{Name: "corel", Age: 23}, {Name: "zen", Age: 18}}, members)
+}
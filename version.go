@@ -0,0 +1,96 @@
+package redisgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// commandVersions 本包用到的需要较新redis版本的命令及其最低版本
+var commandVersions = map[string]string{
+	"KEEPTTL":        "6.0.0", // SET 的 KEEPTTL 选项
+	"GETEX":          "6.2.0",
+	"GETDEL":         "6.2.0",
+	"COPY":           "6.2.0",
+	"GEOSEARCH":      "6.2.0",
+	"GEOSEARCHSTORE": "6.2.0",
+	"HRANDFIELD":     "6.2.0",
+	"ZRANGESTORE":    "6.2.0",
+	"ZMSCORE":        "6.2.0",
+	"ZDIFF":          "6.2.0",
+	"ZDIFFSTORE":     "6.2.0",
+	"RESET":          "6.2.0",
+	"LMOVE":          "6.2.0",
+	"LMPOP":          "7.0.0",
+	"BLMPOP":         "7.0.0",
+	"SINTERCARD":     "7.0.0",
+	"LCS":            "7.0.0",
+	"EXPIRE NX":      "7.0.0", // EXPIRE 的条件选项
+	"EXPIRE XX":      "7.0.0",
+	"EXPIRE GT":      "7.0.0",
+	"EXPIRE LT":      "7.0.0",
+	"HEXPIRE":        "7.4.0",
+	"HTTL":           "7.4.0",
+	"HPERSIST":       "7.4.0",
+}
+
+// ServerVersion 返回初始化时从 INFO server 获取的redis服务版本号，获取失败时返回空字符串
+func (c *Cacher) ServerVersion() string {
+	return c.state().serverVersion
+}
+
+// Supports 检查redis服务是否支持指定的命令。只检查本包用到的较新版本的命令，
+// 其他命令以及无法获取服务版本时都返回 true 。
+func (c *Cacher) Supports(commandName string) bool {
+	required, ok := commandVersions[strings.ToUpper(commandName)]
+	version := c.ServerVersion()
+	if !ok || version == "" {
+		return true
+	}
+	return compareVersion(version, required) >= 0
+}
+
+// checkSupport 在redis服务不支持命令时返回明确的错误，而不是redis返回的 unknown command
+func (c *Cacher) checkSupport(commandName string) error {
+	if c.Supports(commandName) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s requires redis %s, server is %s", ErrUnsupported, commandName, commandVersions[commandName], c.ServerVersion())
+}
+
+// probeServerVersion 通过 INFO server 获取redis服务版本号，失败时返回空字符串
+func probeServerVersion(conn redis.Conn) string {
+	info, err := String(conn.Do("INFO", "server"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(info, "\n") {
+		if strings.HasPrefix(line, "redis_version:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "redis_version:"))
+		}
+	}
+	return ""
+}
+
+// compareVersion 比较两个 x.y.z 格式的版本号，a小于、等于、大于b时分别返回-1、0、1
+func compareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}